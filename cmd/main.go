@@ -4,18 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/roffe/gocan"
 	"github.com/roffe/gocan/adapter"
+	"github.com/roffe/t7logger/pkg/datalogger"
 	"github.com/roffe/t7logger/pkg/kwp2000"
+	"github.com/roffe/t7logger/pkg/symbol"
 )
 
 var vars []*kwp2000.VarDefinition
@@ -39,6 +43,17 @@ func init() {
 
 var freq = 50
 
+var (
+	freezeFramesFile = flag.String("freezeframes", "", "read DTCs with freeze frames, write them as CSV to file (- for stdout) and exit")
+	freezeFrameSyms  = flag.String("freezeframesymbols", "", "comma separated symbols a freeze frame holds, in order, replaces the built-in guess")
+	symbolsFile      = flag.String("symbols", "", "binary to read symbol metadata from, config.json is used if not set")
+	timeout          = flag.Duration("timeout", 250*time.Millisecond, "time to wait for a response from the ECU")
+	readTimeout      = flag.Duration("readtimeout", 50*time.Millisecond, "time to wait for a live data response from the ECU")
+	reqID            = flag.Uint("reqid", uint(kwp2000.REQ_MSG_ID), "CAN id requests are sent on")
+	convertFile      = flag.String("convert", "", "convert a .t7l log to .msl next to it using the units in config.json and exit")
+	logFormat        = flag.String("format", datalogger.LogFormats[0], "log format, one of "+strings.Join(datalogger.LogFormats, ", "))
+)
+
 /*
 var vars = []*kwp2000.VarDefinition{
 	{
@@ -125,15 +140,17 @@ var vars = []*kwp2000.VarDefinition{
 */
 
 func main() {
-	quitChan := make(chan os.Signal, 2)
-	signal.Notify(quitChan, os.Interrupt, syscall.SIGTERM)
+	flag.Parse()
 
-	// Open the log file for writing
-	file, err := os.OpenFile("mylog.t7l", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatal(err)
+	if *convertFile != "" {
+		if err := convertLog(*convertFile); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	defer file.Close()
+
+	quitChan := make(chan os.Signal, 2)
+	signal.Notify(quitChan, os.Interrupt, syscall.SIGTERM)
 
 	var devName string
 	for _, d := range adapter.List() {
@@ -164,11 +181,56 @@ func main() {
 	}
 	defer c.Close()
 
-	k := kwp2000.New(c)
-	if err := k.StartSession(ctx, kwp2000.INIT_MSG_ID, kwp2000.INIT_RESP_ID); err != nil {
+	k := kwp2000.New(c,
+		kwp2000.WithTimeout(*timeout),
+		kwp2000.WithReadDataTimeout(*readTimeout),
+		kwp2000.WithRequestID(uint32(*reqID)),
+	)
+	if err := k.StartSession(ctx); err != nil {
+		log.Println(err)
+		return
+	}
+
+	if *freezeFramesFile != "" {
+		if err := dumpFreezeFrames(ctx, k, *freezeFramesFile); err != nil {
+			log.Println(err)
+		}
+		if err := k.StopSession(ctx); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	// Open the log file for writing, after the freeze frame dump which doesn't log
+	// t7l logs are appended to, the other formats have a header and mf4 updates its blocks in place
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if *logFormat == "t7l" {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile("mylog."+*logFormat, flags, 0666)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	lw, err := datalogger.NewLogWriter(*logFormat, file)
+	if err != nil {
+		file.Close()
+		os.Remove("mylog." + *logFormat)
 		log.Println(err)
 		return
 	}
+	defer lw.Close()
+
+	ident, err := k.ReadECUIdentification(ctx)
+	if err != nil {
+		log.Println(err)
+	}
+	if ident != nil {
+		log.Println(ident.String())
+		if err := lw.WriteIdentification(ident); err != nil {
+			log.Println(err)
+		}
+	}
 
 	log.Println("Defining DynamicallyDefineLocalId's...")
 	for i, v := range vars {
@@ -224,7 +286,9 @@ func main() {
 
 			fmt.Printf("Frames captured: %d\n\033[A", count)
 
-			produceLogLine(file, vars)
+			if err := lw.Write(time.Now(), vars); err != nil {
+				log.Println(err)
+			}
 
 			count++
 		}
@@ -232,13 +296,67 @@ func main() {
 
 }
 
-var out strings.Builder
+func convertLog(filename string) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(strings.TrimSuffix(filename, filepath.Ext(filename)) + ".msl")
+	if err != nil {
+		return err
+	}
+	if err := datalogger.ConvertT7L(in, out, datalogger.ChannelUnits(vars)); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func dumpFreezeFrames(ctx context.Context, k *kwp2000.Client, filename string) error {
+	if *freezeFrameSyms != "" {
+		kwp2000.FreezeFrameSymbols = strings.Split(*freezeFrameSyms, ",")
+	}
+	symbols := make(map[string]*kwp2000.VarDefinition)
+	for _, v := range vars {
+		symbols[v.Name] = v
+	}
+	if *symbolsFile != "" {
+		syms, err := symbol.LoadSymbols(*symbolsFile, func(s string) { log.Println(s) })
+		if err != nil {
+			return err
+		}
+		for _, s := range syms {
+			symbols[s.Name] = &kwp2000.VarDefinition{
+				Name:             s.Name,
+				Method:           kwp2000.VAR_METHOD_SYMBOL,
+				Value:            s.Number,
+				Type:             s.Type,
+				Length:           s.Length,
+				Mask:             s.Mask,
+				Correctionfactor: s.Correctionfactor,
+				Unit:             s.Unit,
+			}
+		}
+	}
+
+	dtcs, err := k.ReadDiagnosticTroubleCodesByStatus(ctx)
+	if err != nil {
+		return err
+	}
+	frames, err := k.ReadFreezeFrames(ctx, dtcs)
+	if err != nil {
+		return err
+	}
 
-func produceLogLine(file io.Writer, vars []*kwp2000.VarDefinition) {
-	out.WriteString("|")
-	for _, va := range vars {
-		out.WriteString(va.String() + "|")
+	var out io.Writer = os.Stdout
+	if filename != "-" {
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
 	}
-	fmt.Fprint(file, time.Now().Format("02-01-2006 15:04:05.999")+out.String()+"IMPORTANTLINE=0|\n")
-	out.Reset()
+	return kwp2000.WriteFreezeFrames(out, frames, symbols)
 }
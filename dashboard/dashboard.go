@@ -22,7 +22,7 @@ import (
 
 type SymbolDefinition struct {
 	Name  string
-	ID    int
+	ID    string
 	Type  string
 	Unit  string
 	Group string
@@ -95,13 +95,24 @@ func Start(logFn func(string), releaseMode bool, sm *sink.Manager, vars *kwp2000
 	server.OnEvent("/", "request_symbols", func(s socketio.Conn) {
 		var symbolList []SymbolDefinition
 		for _, v := range vars.Get() {
-			symbolList = append(symbolList, SymbolDefinition{
-				Name:  v.Name,
-				ID:    v.Value,
-				Type:  returnVis(v.Visualization),
-				Unit:  v.Unit,
-				Group: v.Group,
-			})
+			names, ids := v.Channels()
+			unit, group := v.Unit, v.Group
+			if v.IsBitfield() {
+				unit = ""
+			}
+			if len(names) > 1 && group == "" {
+				// bits and array elements share a graph
+				group = v.Name
+			}
+			for i, name := range names {
+				symbolList = append(symbolList, SymbolDefinition{
+					Name:  name,
+					ID:    ids[i],
+					Type:  returnVis(v.Visualization),
+					Unit:  unit,
+					Group: group,
+				})
+			}
 		}
 		s.Emit("symbol_list", symbolList)
 	})
@@ -0,0 +1,143 @@
+package broadcast
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+// Signal is a value carried in every frame broadcast with a CAN id
+type Signal struct {
+	Name string
+	ID   uint32
+	// StartBit and Length locate the raw value in the frame. For big endian (Motorola) signals
+	// StartBit is the most significant bit, for little endian (Intel) the least significant,
+	// bits are numbered from bit 0 of byte 0 upwards. Signals longer than 32 bits can't be logged
+	StartBit     int
+	Length       int
+	LittleEndian bool
+	Signed       bool
+	// the physical value is raw*Factor+Offset
+	Factor float64
+	Offset float64
+	Unit   string
+}
+
+// Raw extracts the raw value from frame data, ok is false if the frame is too short to hold it
+func (s *Signal) Raw(data []byte) (raw int64, ok bool) {
+	if s.Length < 1 || s.Length > 64 {
+		return 0, false
+	}
+	var v uint64
+	bit := s.StartBit
+	for i := 0; i < s.Length; i++ {
+		if bit < 0 || bit/8 >= len(data) {
+			return 0, false
+		}
+		b := uint64(data[bit/8]>>(bit%8)) & 1
+		if s.LittleEndian {
+			v |= b << i
+			bit++
+		} else {
+			v = v<<1 | b
+			// Motorola bits run from the msb of a byte down to its lsb and continue at the msb of the next byte
+			if bit%8 == 0 {
+				bit += 15
+			} else {
+				bit--
+			}
+		}
+	}
+	if s.Signed && s.Length < 64 && v&(1<<(s.Length-1)) != 0 {
+		v |= ^uint64(0) << s.Length
+	}
+	return int64(v), true
+}
+
+// Value returns the physical value of the signal in frame data
+func (s *Signal) Value(data []byte) (float64, bool) {
+	raw, ok := s.Raw(data)
+	if !ok {
+		return 0, false
+	}
+	factor := s.Factor
+	if factor == 0 {
+		factor = 1
+	}
+	return float64(raw)*factor + s.Offset, true
+}
+
+// storage returns the width and type of the definition holding the raw value, the smallest of
+// 1, 2 or 4 bytes the signal fits in. Signals longer than 32 bits can't be stored
+func (s *Signal) storage() (length uint16, typ uint8) {
+	switch {
+	case s.Length <= 8:
+		length = 1
+	case s.Length <= 16:
+		length = 2
+	default:
+		length = 4
+		typ = kwp2000.LONG
+	}
+	if s.Signed {
+		typ |= kwp2000.SIGNED
+	}
+	return length, typ
+}
+
+// VarDefinition returns a definition the raw value is stored in so the signal is logged like a symbol,
+// the factor and offset become the correction factor
+func (s *Signal) VarDefinition(number int) *kwp2000.VarDefinition {
+	length, typ := s.storage()
+	v := &kwp2000.VarDefinition{
+		Name:   s.Name,
+		Method: kwp2000.VAR_METHOD_ADDRESS,
+		Value:  number,
+		Type:   typ,
+		Length: length,
+		Unit:   s.Unit,
+		Group:  fmt.Sprintf("0x%03X", s.ID),
+	}
+	if (s.Factor != 0 && s.Factor != 1) || s.Offset != 0 {
+		factor := s.Factor
+		if factor == 0 {
+			factor = 1
+		}
+		v.Correctionfactor = strconv.FormatFloat(factor, 'f', -1, 64)
+		if s.Offset != 0 {
+			// the correction factor is evaluated as raw*factor so an offset can be appended
+			v.Correctionfactor += fmt.Sprintf("%+g", s.Offset)
+		}
+	}
+	v.Set(make([]byte, length))
+	return v
+}
+
+// SetRaw stores a raw value in a definition created by VarDefinition, big endian in the width of the definition
+func SetRaw(v *kwp2000.VarDefinition, raw int64) {
+	b := make([]byte, v.Length)
+	switch v.Length {
+	case 1:
+		b[0] = byte(raw)
+	case 2:
+		binary.BigEndian.PutUint16(b, uint16(raw))
+	default:
+		binary.BigEndian.PutUint32(b, uint32(raw))
+	}
+	v.Set(b)
+}
+
+// IDs returns the distinct CAN ids of signals, in order of appearance
+func IDs(signals []*Signal) []uint32 {
+	var ids []uint32
+	seen := make(map[uint32]bool)
+	for _, s := range signals {
+		if !seen[s.ID] {
+			seen[s.ID] = true
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,75 @@
+package broadcast
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+func TestSignalRaw(t *testing.T) {
+	tests := []struct {
+		name   string
+		signal Signal
+		data   []byte
+		raw    int64
+		ok     bool
+	}{
+		{"intel 16", Signal{StartBit: 0, Length: 16, LittleEndian: true}, []byte{0x34, 0x12}, 0x1234, true},
+		{"intel cross byte", Signal{StartBit: 4, Length: 12, LittleEndian: true}, []byte{0xAB, 0xCD}, 0xCDA, true},
+		{"intel nibble", Signal{StartBit: 2, Length: 4, LittleEndian: true}, []byte{0x3C}, 15, true},
+		{"intel signed nibble", Signal{StartBit: 2, Length: 4, LittleEndian: true, Signed: true}, []byte{0x3C}, -1, true},
+		{"intel signed second byte", Signal{StartBit: 8, Length: 8, LittleEndian: true, Signed: true}, []byte{0x00, 0xFE}, -2, true},
+		{"intel unsigned 32", Signal{StartBit: 0, Length: 32, LittleEndian: true}, []byte{0xFF, 0xFF, 0xFF, 0xFF}, 0xFFFFFFFF, true},
+		{"motorola 16", Signal{StartBit: 7, Length: 16}, []byte{0x12, 0x34}, 0x1234, true},
+		{"motorola top 12", Signal{StartBit: 7, Length: 12}, []byte{0xAB, 0xCD}, 0xABC, true},
+		// the msb is bit 3 of byte 0, the value continues at bit 7 of byte 1
+		{"motorola sawtooth", Signal{StartBit: 3, Length: 12}, []byte{0xAB, 0xCD}, 0xBCD, true},
+		{"motorola signed", Signal{StartBit: 7, Length: 16, Signed: true}, []byte{0xFF, 0x38}, -200, true},
+		{"motorola third byte", Signal{StartBit: 23, Length: 8}, []byte{0x00, 0x00, 0x80}, 128, true},
+		{"motorola signed third byte", Signal{StartBit: 23, Length: 8, Signed: true}, []byte{0x00, 0x00, 0x80}, -128, true},
+		{"motorola short frame", Signal{StartBit: 15, Length: 16}, []byte{0x01, 0x02}, 0, false},
+		{"intel short frame", Signal{StartBit: 0, Length: 16, LittleEndian: true}, []byte{0x01}, 0, false},
+		{"no length", Signal{StartBit: 0, Length: 0}, []byte{0x01}, 0, false},
+	}
+	for _, tt := range tests {
+		raw, ok := tt.signal.Raw(tt.data)
+		if raw != tt.raw || ok != tt.ok {
+			t.Errorf("%s: got %d %v, want %d %v", tt.name, raw, ok, tt.raw, tt.ok)
+		}
+	}
+}
+
+func TestSetRaw(t *testing.T) {
+	tests := []struct {
+		name   string
+		signal Signal
+		raw    int64
+		length uint16
+		typ    uint8
+		data   []byte
+		value  string
+	}{
+		{"unsigned 8", Signal{Length: 8}, 200, 1, 0, []byte{0xC8}, "200"},
+		{"signed 8", Signal{Length: 8, Signed: true}, -128, 1, kwp2000.SIGNED, []byte{0x80}, "-128"},
+		{"signed 12", Signal{Length: 12, Signed: true}, -1, 2, kwp2000.SIGNED, []byte{0xFF, 0xFF}, "-1"},
+		{"unsigned 16", Signal{Length: 16}, 0xFFFF, 2, 0, []byte{0xFF, 0xFF}, "65535"},
+		{"unsigned 32", Signal{Length: 32}, 0xFFFFFFFF, 4, kwp2000.LONG, []byte{0xFF, 0xFF, 0xFF, 0xFF}, "4294967295"},
+		{"signed 32", Signal{Length: 32, Signed: true}, -2, 4, kwp2000.LONG | kwp2000.SIGNED, []byte{0xFF, 0xFF, 0xFF, 0xFE}, "-2"},
+		{"scaled", Signal{Length: 16, Factor: 0.5, Offset: -40}, 100, 2, 0, []byte{0x00, 0x64}, "10"},
+	}
+	for _, tt := range tests {
+		v := tt.signal.VarDefinition(0)
+		if v.Length != tt.length || v.Type != tt.typ {
+			t.Errorf("%s: stored in %d bytes of type 0x%02X, want %d bytes of type 0x%02X", tt.name, v.Length, v.Type, tt.length, tt.typ)
+			continue
+		}
+		SetRaw(v, tt.raw)
+		if !bytes.Equal(v.Data(), tt.data) {
+			t.Errorf("%s: stored % X, want % X", tt.name, v.Data(), tt.data)
+		}
+		if got := v.StringValue(); got != tt.value {
+			t.Errorf("%s: value %s, want %s", tt.name, got, tt.value)
+		}
+	}
+}
@@ -2,11 +2,18 @@ package datalogger
 
 import (
 	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2/data/binding"
 	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/broadcast"
 	"github.com/roffe/t7logger/pkg/kwp2000"
 	"github.com/roffe/t7logger/pkg/sink"
+	"github.com/roffe/t7logger/pkg/t5"
+	"github.com/roffe/t7logger/pkg/t8"
 )
 
 const ISO8601 = "2006-01-02T15:04:05.999-0700"
@@ -14,6 +21,8 @@ const ISO8601 = "2006-01-02T15:04:05.999-0700"
 type DataClient interface {
 	Start() error
 	Close()
+	// Write writes raw data to a variable in RAM using the running session
+	Write(v *kwp2000.VarDefinition, data []byte) error
 }
 
 type Config struct {
@@ -22,17 +31,106 @@ type Config struct {
 	Variables             []*kwp2000.VarDefinition
 	Freq                  int
 	OnMessage             func(string)
+	OnIdentification      func(*kwp2000.ECUIdentification)
 	CaptureCounter        binding.Int
 	ErrorCounter          binding.Int
 	ErrorPerSecondCounter binding.Int
 	Sink                  *sink.Manager
+	// KWPOptions tunes timeouts and CAN ids of the KWP client, for slow adapters or gateways
+	KWPOptions []kwp2000.Option
+	// T8Options tunes timeouts and CAN ids of the T8 client
+	T8Options []t8.Option
+	// T5Options tunes the timeout of the T5 client
+	T5Options []t5.Option
+	// Passive logs the values the ECU broadcasts instead of polling it, nothing is sent on the bus
+	Passive bool
+	// Signals decoded in passive mode, the known broadcast signals of the ECU are used if empty
+	Signals []*broadcast.Signal
+	// LogFormat is one or more of LogFormats separated by comma, t7l if empty
+	LogFormat string
 }
 
 func New(cfg Config) (DataClient, error) {
+	if cfg.Passive {
+		return NewPassive(cfg)
+	}
 	switch cfg.ECU {
+	case "T5":
+		return NewT5(cfg)
 	case "T7":
 		return NewT7(cfg)
+	case "T8":
+		return NewT8(cfg)
 	default:
 		return nil, fmt.Errorf("%s not supported yet", cfg.ECU)
 	}
 }
+
+// createLog creates a new log file named after the current time in the logs dir for every
+// format in the comma separated LogFormat
+func (c *Config) createLog() (LogWriter, error) {
+	if _, err := os.Stat("logs"); os.IsNotExist(err) {
+		if err := os.Mkdir("logs", 0755); err != nil {
+			if err != os.ErrExist {
+				return nil, fmt.Errorf("failed to create logs dir: %w", err)
+			}
+		}
+	}
+	formats := strings.Split(c.LogFormat, ",")
+	if c.LogFormat == "" {
+		formats = LogFormats[:1]
+	}
+	name := fmt.Sprintf("logs/log-%s", time.Now().Format("2006-01-02-15-04-05"))
+	var writers multiLogWriter
+	for _, format := range formats {
+		lw, err := openLog(name+"."+format, format)
+		if err != nil {
+			writers.Close()
+			return nil, err
+		}
+		c.OnMessage(fmt.Sprintf("Logging to %s.%s", name, format))
+		writers = append(writers, lw)
+	}
+	if len(writers) == 1 {
+		return writers[0], nil
+	}
+	return writers, nil
+}
+
+func openLog(filename, format string) (LogWriter, error) {
+	// not O_APPEND, mf4 updates its blocks in place
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	lw, err := NewLogWriter(format, file)
+	if err != nil {
+		file.Close()
+		os.Remove(filename)
+		return nil, err
+	}
+	return lw, nil
+}
+
+// writeIdentification reports the ECU identification and writes it as the log header
+func (c *Config) writeIdentification(lw LogWriter, ident *kwp2000.ECUIdentification) error {
+	c.OnMessage(ident.String())
+	if c.OnIdentification != nil {
+		c.OnIdentification(ident)
+	}
+	return lw.WriteIdentification(ident)
+}
+
+func (c *Config) produceLogLine(lw LogWriter, vars []*kwp2000.VarDefinition) {
+	now := time.Now()
+	if err := lw.Write(now, vars); err != nil {
+		log.Println("failed to write log:", err)
+	}
+	var ms []string
+	for _, va := range vars {
+		ms = append(ms, va.Tuple())
+	}
+	c.Sink.Push(&sink.Message{
+		Data: []byte(now.Format(ISO8601) + "|" + strings.Join(ms, ",")),
+	})
+}
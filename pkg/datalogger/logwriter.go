@@ -0,0 +1,318 @@
+package datalogger
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roffe/t7logger/pkg/kwp2000"
+	"github.com/roffe/t7logger/pkg/mdf"
+)
+
+// LogFormats are the formats NewLogWriter supports, the first one is the default
+var LogFormats = []string{"t7l", "csv", "jsonl", "mf4", "msl"}
+
+// LogWriter writes the samples of a logging session in one file format
+type LogWriter interface {
+	// WriteIdentification writes the ECU identification, it is called before the first sample if at all
+	WriteIdentification(ident *kwp2000.ECUIdentification) error
+	Write(ts time.Time, vars []*kwp2000.VarDefinition) error
+	Close() error
+}
+
+// NewLogWriter returns a LogWriter writing format to w, w is closed with the writer
+func NewLogWriter(format string, w io.WriteCloser) (LogWriter, error) {
+	switch format {
+	case "", "t7l":
+		return &t7lWriter{w: w}, nil
+	case "csv":
+		return &csvWriter{w: w, cw: csv.NewWriter(w)}, nil
+	case "jsonl":
+		return &jsonlWriter{w: w, enc: json.NewEncoder(w)}, nil
+	case "mf4":
+		f, ok := w.(mdf.File)
+		if !ok {
+			return nil, fmt.Errorf("mf4 logs can only be written to a file")
+		}
+		return &mdfWriter{f: f}, nil
+	case "msl":
+		return &mslWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// multiLogWriter writes every sample to several logs
+type multiLogWriter []LogWriter
+
+// WriteIdentification writes to every log even if one fails, the errors are joined
+func (m multiLogWriter) WriteIdentification(ident *kwp2000.ECUIdentification) error {
+	var errs []error
+	for _, lw := range m {
+		errs = append(errs, lw.WriteIdentification(ident))
+	}
+	return errors.Join(errs...)
+}
+
+// Write writes to every log even if one fails, the errors are joined
+func (m multiLogWriter) Write(ts time.Time, vars []*kwp2000.VarDefinition) error {
+	var errs []error
+	for _, lw := range m {
+		errs = append(errs, lw.Write(ts, vars))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiLogWriter) Close() error {
+	var errs []error
+	for _, lw := range m {
+		errs = append(errs, lw.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// writeIdentificationFile writes the identification as JSON to <log>.ident.json for formats that have
+// no place for it, nothing is written if the log is not a file. The file is replaced if it exists
+func writeIdentificationFile(w io.Writer, ident *kwp2000.ECUIdentification) error {
+	f, ok := w.(interface{ Name() string })
+	if !ok {
+		return nil
+	}
+	fields := make(map[string]string)
+	for _, f := range ident.Fields() {
+		fields[f[0]] = f[1]
+	}
+	b, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.Name()+".ident.json", append(b, '\n'), 0666)
+}
+
+// t7lWriter writes the T7Suite format, pipe separated name=value pairs with comma decimals. Every line
+// is a sample so the identification goes in a <log>.ident.json file next to the log, if the log is a file
+type t7lWriter struct {
+	w io.WriteCloser
+}
+
+func (t *t7lWriter) WriteIdentification(ident *kwp2000.ECUIdentification) error {
+	return writeIdentificationFile(t.w, ident)
+}
+
+func (t *t7lWriter) Write(ts time.Time, vars []*kwp2000.VarDefinition) error {
+	var out strings.Builder
+	out.WriteString(ts.Format("02-01-2006 15:04:05.999") + "|")
+	for _, va := range vars {
+		out.WriteString(va.T7L() + "|")
+	}
+	out.WriteString("IMPORTANTLINE=0|\n")
+	_, err := io.WriteString(t.w, out.String())
+	return err
+}
+
+func (t *t7lWriter) Close() error {
+	return t.w.Close()
+}
+
+// csvWriter writes a header row of channel names followed by one row per sample. The identification
+// would break readers of plain CSV so it goes in a <log>.ident.json file next to the log, if the log is a file
+type csvWriter struct {
+	w      io.WriteCloser
+	cw     *csv.Writer
+	header bool
+}
+
+func (c *csvWriter) WriteIdentification(ident *kwp2000.ECUIdentification) error {
+	return writeIdentificationFile(c.w, ident)
+}
+
+func (c *csvWriter) Write(ts time.Time, vars []*kwp2000.VarDefinition) error {
+	if !c.header {
+		row := []string{"time"}
+		for _, va := range vars {
+			names, _ := va.Channels()
+			row = append(row, names...)
+		}
+		if err := c.cw.Write(row); err != nil {
+			return err
+		}
+		c.header = true
+	}
+	row := []string{ts.Format(ISO8601)}
+	for _, va := range vars {
+		row = append(row, va.Values()...)
+	}
+	if err := c.cw.Write(row); err != nil {
+		return err
+	}
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.cw.Flush()
+	return c.w.Close()
+}
+
+// jsonlWriter writes one JSON object per line, the identification as {"identification":{...}}
+// and every sample as {"time":"...","name":value,...} with numbers where the value is numeric
+type jsonlWriter struct {
+	w   io.WriteCloser
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) WriteIdentification(ident *kwp2000.ECUIdentification) error {
+	fields := make(map[string]string)
+	for _, f := range ident.Fields() {
+		fields[f[0]] = f[1]
+	}
+	return j.enc.Encode(map[string]interface{}{"identification": fields})
+}
+
+func (j *jsonlWriter) Write(ts time.Time, vars []*kwp2000.VarDefinition) error {
+	// keys are written in channel order so lines stay readable
+	var out strings.Builder
+	out.WriteString(`{"time":` + strconv.Quote(ts.Format(ISO8601)))
+	for _, va := range vars {
+		names, _ := va.Channels()
+		// text and raw bytes stay strings even when they look like a number
+		text := va.IsString() || va.IsRaw()
+		for i, value := range va.Values() {
+			name, err := json.Marshal(names[i])
+			if err != nil {
+				return err
+			}
+			out.WriteString("," + string(name) + ":")
+			if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) && !text {
+				out.WriteString(value)
+				continue
+			}
+			b, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			out.Write(b)
+		}
+	}
+	out.WriteString("}\n")
+	_, err := io.WriteString(j.w, out.String())
+	return err
+}
+
+func (j *jsonlWriter) Close() error {
+	return j.w.Close()
+}
+
+// mdfWriter writes ASAM MDF4, every channel holds the raw value with the correction factor as conversion.
+// The file is created on the first sample as the channels follow from the variables
+type mdfWriter struct {
+	f     mdf.File
+	props [][2]string
+	w     *mdf.Writer
+	rec   []byte
+}
+
+func (m *mdfWriter) WriteIdentification(ident *kwp2000.ECUIdentification) error {
+	m.props = ident.Fields()
+	return nil
+}
+
+func (m *mdfWriter) Write(ts time.Time, vars []*kwp2000.VarDefinition) error {
+	if m.w == nil {
+		var channels []mdf.Channel
+		for _, va := range vars {
+			c, err := mdfChannels(va)
+			if err != nil {
+				return err
+			}
+			channels = append(channels, c...)
+		}
+		w, err := mdf.Create(m.f, ts, channels, m.props)
+		if err != nil {
+			return err
+		}
+		m.w = w
+	}
+	m.rec = m.rec[:0]
+	for _, va := range vars {
+		m.rec = appendMDFRecord(m.rec, va)
+	}
+	return m.w.Write(ts, m.rec)
+}
+
+func (m *mdfWriter) Close() error {
+	if m.w == nil {
+		return m.f.Close()
+	}
+	return m.w.Close()
+}
+
+// mdfChannels returns the channels a variable decodes into, in the order of Channels
+func mdfChannels(v *kwp2000.VarDefinition) ([]mdf.Channel, error) {
+	switch {
+	case v.IsBitfield():
+		var out []mdf.Channel
+		for _, name := range v.BitNames() {
+			out = append(out, mdf.Channel{Name: name, DataType: mdf.UnsignedLE, Size: 1})
+		}
+		return out, nil
+	case v.IsString():
+		return []mdf.Channel{{Name: v.Name, DataType: mdf.String, Size: int(v.Length)}}, nil
+	case v.IsRaw():
+		return []mdf.Channel{{Name: v.Name, DataType: mdf.ByteArray, Size: int(v.Length)}}, nil
+	case v.IsArray():
+		var out []mdf.Channel
+		for _, e := range v.Elements() {
+			c, err := mdfChannels(e)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, c...)
+		}
+		return out, nil
+	}
+	c := mdf.Channel{Name: v.Name, Unit: v.Unit, DataType: mdf.UnsignedBE, Size: int(v.Length)}
+	if v.Type&kwp2000.SIGNED != 0 {
+		c.DataType = mdf.SignedBE
+	}
+	factor, offset, err := v.Linear()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", v.Name, err)
+	}
+	if factor != 1 || offset != 0 {
+		c.Factor, c.Offset = factor, offset
+	}
+	return []mdf.Channel{c}, nil
+}
+
+// appendMDFRecord appends the raw values of a variable laid out as mdfChannels describes them
+func appendMDFRecord(rec []byte, v *kwp2000.VarDefinition) []byte {
+	switch {
+	case v.IsBitfield():
+		for _, b := range v.Bits() {
+			if b {
+				rec = append(rec, 1)
+			} else {
+				rec = append(rec, 0)
+			}
+		}
+		return rec
+	case v.IsArray():
+		for _, e := range v.Elements() {
+			rec = appendMDFRecord(rec, e)
+		}
+		return rec
+	}
+	data := v.Data()
+	if len(data) != int(v.Length) {
+		// nothing read yet
+		return append(rec, make([]byte, v.Length)...)
+	}
+	return append(rec, data...)
+}
@@ -0,0 +1,181 @@
+package datalogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+// bufferCloser is a log that is not a file
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+var testTime = time.Date(2023, 5, 1, 12, 30, 15, 250e6, time.UTC)
+
+func testVars() []*kwp2000.VarDefinition {
+	rpm := &kwp2000.VarDefinition{Name: "ActualIn.n_Engine", Length: 2}
+	rpm.Set([]byte{0x03, 0xE8})
+	boost := &kwp2000.VarDefinition{Name: "In.p_AirInlet", Length: 2, Type: kwp2000.SIGNED, Correctionfactor: "0.0015"}
+	boost.Set([]byte{0xFC, 0x18})
+	text := &kwp2000.VarDefinition{Name: "E85.Text", Length: 3, Type: kwp2000.CHAR}
+	text.Set([]byte("E85"))
+	return []*kwp2000.VarDefinition{rpm, boost, text}
+}
+
+func writeSamples(t *testing.T, format string, w *bufferCloser) string {
+	t.Helper()
+	lw, err := NewLogWriter(format, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lw.WriteIdentification(&kwp2000.ECUIdentification{VIN: "YS3FH41U571000001"}); err != nil {
+		t.Fatal(err)
+	}
+	vars := testVars()
+	for i := 0; i < 2; i++ {
+		if err := lw.Write(testTime.Add(time.Duration(i)*time.Second), vars); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !w.closed {
+		t.Error("log not closed")
+	}
+	return w.String()
+}
+
+func TestCSVWriter(t *testing.T) {
+	got := writeSamples(t, "csv", &bufferCloser{})
+	want := "time,ActualIn.n_Engine,In.p_AirInlet,E85.Text\n" +
+		"2023-05-01T12:30:15.25+0000,1000,-1.5,E85\n" +
+		"2023-05-01T12:30:16.25+0000,1000,-1.5,E85\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(writeSamples(t, "jsonl", &bufferCloser{}), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	if lines[0] != `{"identification":{"EngineType":"","HardwareNumber":"","ImmobilizerCode":"","SoftwarePartNumber":"","SoftwareVersion":"","VIN":"YS3FH41U571000001"}}` {
+		t.Errorf("identification line %s", lines[0])
+	}
+	want := `{"time":"2023-05-01T12:30:15.25+0000","ActualIn.n_Engine":1000,"In.p_AirInlet":-1.5,"E85.Text":"E85"}`
+	if lines[1] != want {
+		t.Errorf("got %s, want %s", lines[1], want)
+	}
+	var sample map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[2]), &sample); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sample["In.p_AirInlet"].(float64); !ok {
+		t.Errorf("In.p_AirInlet is %T, want a number", sample["In.p_AirInlet"])
+	}
+	if _, ok := sample["E85.Text"].(string); !ok {
+		t.Errorf("E85.Text is %T, want a string", sample["E85.Text"])
+	}
+}
+
+func TestT7LWriter(t *testing.T) {
+	got := writeSamples(t, "t7l", &bufferCloser{})
+	want := "01-05-2023 12:30:15.25|ActualIn.n_Engine=1000|In.p_AirInlet=-1,5|E85.Text=E85|IMPORTANTLINE=0|\n" +
+		"01-05-2023 12:30:16.25|ActualIn.n_Engine=1000|In.p_AirInlet=-1,5|E85.Text=E85|IMPORTANTLINE=0|\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestIdentificationFile(t *testing.T) {
+	for _, format := range []string{"csv", "t7l"} {
+		filename := filepath.Join(t.TempDir(), "log."+format)
+		f, err := os.Create(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lw, err := NewLogWriter(format, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := lw.WriteIdentification(&kwp2000.ECUIdentification{VIN: "YS3FH41U571000001"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := lw.Write(testTime, testVars()); err != nil {
+			t.Fatal(err)
+		}
+		lw.Close()
+
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(b), "YS3FH41U571000001") {
+			t.Errorf("%s: identification written to the log", format)
+		}
+		b, err = os.ReadFile(filename + ".ident.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ident map[string]string
+		if err := json.Unmarshal(b, &ident); err != nil {
+			t.Fatal(err)
+		}
+		if ident["VIN"] != "YS3FH41U571000001" {
+			t.Errorf("%s: identification file holds %s", format, b)
+		}
+	}
+}
+
+// failingWriter fails every call with err
+type failingWriter struct {
+	err error
+}
+
+func (f failingWriter) WriteIdentification(*kwp2000.ECUIdentification) error { return f.err }
+func (f failingWriter) Write(time.Time, []*kwp2000.VarDefinition) error      { return f.err }
+func (f failingWriter) Close() error                                         { return f.err }
+
+func TestMultiLogWriter(t *testing.T) {
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+	buf := &bufferCloser{}
+	m := multiLogWriter{failingWriter{errA}, &t7lWriter{w: buf}, failingWriter{errB}}
+
+	err := m.Write(testTime, testVars())
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("Write returned %v, want both errors", err)
+	}
+	if buf.Len() == 0 {
+		t.Error("the sample was not written to the log after the failing one")
+	}
+	if err := m.WriteIdentification(&kwp2000.ECUIdentification{}); !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("WriteIdentification returned %v, want both errors", err)
+	}
+	if err := m.Close(); !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("Close returned %v, want both errors", err)
+	}
+	if !buf.closed {
+		t.Error("the log after the failing one was not closed")
+	}
+
+	if err := (multiLogWriter{&t7lWriter{w: &bufferCloser{}}}).Write(testTime, testVars()); err != nil {
+		t.Errorf("Write without failures returned %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package datalogger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+// mslWriter writes the MegaLogViewer ASCII format, quoted header lines followed by tab separated
+// rows of channel names, units and values with the time in seconds since the first sample.
+// CHAR arrays and raw STRUCTs are left out as MegaLogViewer only plots numbers
+type mslWriter struct {
+	w      io.WriteCloser
+	start  time.Time
+	header bool
+}
+
+func (m *mslWriter) WriteIdentification(ident *kwp2000.ECUIdentification) error {
+	if m.header {
+		return nil
+	}
+	for _, f := range ident.Fields() {
+		if _, err := fmt.Fprintf(m.w, "\"%s: %s\"\n", f[0], strings.ReplaceAll(f[1], `"`, "'")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mslWriter) Write(ts time.Time, vars []*kwp2000.VarDefinition) error {
+	var out strings.Builder
+	if !m.header {
+		m.start = ts
+		names := []string{"Time"}
+		units := []string{"s"}
+		for _, va := range vars {
+			if va.IsString() || va.IsRaw() {
+				continue
+			}
+			n, _ := va.Channels()
+			names = append(names, n...)
+			for range n {
+				units = append(units, channelUnit(va))
+			}
+		}
+		fmt.Fprintf(&out, "\"Trionic logger\"\n\"Capture Date: %s\"\n", ts.Format(time.RFC1123))
+		out.WriteString(strings.Join(names, "\t") + "\n")
+		out.WriteString(strings.Join(units, "\t") + "\n")
+		m.header = true
+	}
+	out.WriteString(strconv.FormatFloat(ts.Sub(m.start).Seconds(), 'f', 3, 64))
+	for _, va := range vars {
+		if va.IsString() || va.IsRaw() {
+			continue
+		}
+		for _, v := range va.Values() {
+			out.WriteString("\t" + v)
+		}
+	}
+	out.WriteString("\n")
+	_, err := io.WriteString(m.w, out.String())
+	return err
+}
+
+func (m *mslWriter) Close() error {
+	return m.w.Close()
+}
+
+// channelUnit returns the unit of the channels of a variable, bits have none
+func channelUnit(v *kwp2000.VarDefinition) string {
+	if v.IsBitfield() {
+		return ""
+	}
+	return v.Unit
+}
+
+// ChannelUnits maps the channel names of vars to their unit, for converting logs that do not store units
+func ChannelUnits(vars []*kwp2000.VarDefinition) map[string]string {
+	out := make(map[string]string)
+	for _, va := range vars {
+		names, _ := va.Channels()
+		for _, n := range names {
+			out[n] = channelUnit(va)
+		}
+	}
+	return out
+}
+
+// ConvertT7L converts a .t7l log to the MegaLogViewer format, units are looked up by channel name.
+// Values are already scaled in .t7l logs, the channels of the first sample become the columns
+// and channels that are not numbers there are left out
+func ConvertT7L(r io.Reader, w io.Writer, units map[string]string) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	bw := bufio.NewWriter(w)
+	var (
+		columns []string
+		start   time.Time
+		line    int
+	)
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "#") {
+			if columns == nil {
+				k, v, _ := strings.Cut(text[1:], "=")
+				fmt.Fprintf(bw, "\"%s: %s\"\n", k, strings.ReplaceAll(v, `"`, "'"))
+			}
+			continue
+		}
+		fields := strings.Split(strings.TrimSuffix(text, "|"), "|")
+		ts, err := time.ParseInLocation("02-01-2006 15:04:05.999", fields[0], time.Local)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid time: %w", line, err)
+		}
+		values := make(map[string]string)
+		var order []string
+		for _, f := range fields[1:] {
+			k, v, ok := strings.Cut(f, "=")
+			if !ok || k == "IMPORTANTLINE" {
+				continue
+			}
+			v = strings.ReplaceAll(v, ",", ".")
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+			values[k] = v
+			order = append(order, k)
+		}
+		if columns == nil {
+			columns = order
+			start = ts
+			fmt.Fprintf(bw, "\"Trionic logger\"\n\"Capture Date: %s\"\n", ts.Format(time.RFC1123))
+			bw.WriteString("Time\t" + strings.Join(columns, "\t") + "\n")
+			bw.WriteString("s")
+			for _, c := range columns {
+				bw.WriteString("\t" + units[c])
+			}
+			bw.WriteString("\n")
+		}
+		bw.WriteString(strconv.FormatFloat(ts.Sub(start).Seconds(), 'f', 3, 64))
+		for _, c := range columns {
+			bw.WriteString("\t" + values[c])
+		}
+		bw.WriteString("\n")
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if columns == nil {
+		return fmt.Errorf("no samples found")
+	}
+	return bw.Flush()
+}
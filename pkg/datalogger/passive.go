@@ -0,0 +1,131 @@
+package datalogger
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/broadcast"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+// PassiveClient logs the values the ECU broadcasts without sending anything on the bus
+type PassiveClient struct {
+	quitChan chan struct{}
+	signals  []*broadcast.Signal
+	Config
+}
+
+func NewPassive(cfg Config) (*PassiveClient, error) {
+	signals, err := PassiveSignals(cfg.Signals)
+	if err != nil {
+		return nil, err
+	}
+	return &PassiveClient{
+		quitChan: make(chan struct{}, 2),
+		signals:  signals,
+		Config:   cfg,
+	}, nil
+}
+
+// PassiveSignals returns the signals to log passively. There is no built-in table of what an ECU
+// broadcasts as none is verified, the signals come from a DBC file
+func PassiveSignals(signals []*broadcast.Signal) ([]*broadcast.Signal, error) {
+	if len(signals) == 0 {
+		return nil, fmt.Errorf("no broadcast signals, load a DBC file describing the frames to log")
+	}
+	return signals, nil
+}
+
+func (c *PassiveClient) Close() {
+	c.quitChan <- struct{}{}
+	time.Sleep(200 * time.Millisecond)
+}
+
+func (c *PassiveClient) Write(v *kwp2000.VarDefinition, data []byte) error {
+	return fmt.Errorf("failed to write %s: passive logging does not send anything to the ECU", v.Name)
+}
+
+func (c *PassiveClient) Start() error {
+	vars := make([]*kwp2000.VarDefinition, len(c.signals))
+	byID := make(map[uint32][]int)
+	for i, s := range c.signals {
+		vars[i] = s.VarDefinition(i)
+		byID[s.ID] = append(byID[s.ID], i)
+	}
+
+	lw, err := c.createLog()
+	if err != nil {
+		return err
+	}
+	defer lw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cl, err := gocan.New(ctx, c.Dev)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	frames := cl.Subscribe(ctx, broadcast.IDs(c.signals)...)
+
+	count := 0
+	// there are no requests to fail, the counters stay at 0
+	c.ErrorCounter.Set(0)
+	c.ErrorPerSecondCounter.Set(0)
+
+	fps := 0
+	received := false
+	quiet := 0
+
+	secondTicker := time.NewTicker(time.Second)
+	defer secondTicker.Stop()
+
+	t := time.NewTicker(time.Second / time.Duration(c.Freq))
+	defer t.Stop()
+
+	c.OnMessage(fmt.Sprintf("Listening for %d broadcast signals, logging at %d fps", len(c.signals), c.Freq))
+	for {
+		select {
+		case <-c.quitChan:
+			c.OnMessage("Stop logging...")
+			return nil
+		case f, ok := <-frames:
+			if !ok {
+				return fmt.Errorf("adapter closed")
+			}
+			fps++
+			received = true
+			for _, i := range byID[f.Identifier()] {
+				raw, ok := c.signals[i].Raw(f.Data())
+				if !ok {
+					// the frame is shorter than the DBC says, the signal keeps its last value
+					continue
+				}
+				broadcast.SetRaw(vars[i], raw)
+			}
+		case <-secondTicker.C:
+			log.Println("broadcast fps:", fps)
+			if fps == 0 {
+				if quiet++; quiet == 5 {
+					c.OnMessage("No broadcast frames received for 5 seconds, is the ignition on?")
+				}
+			} else {
+				quiet = 0
+			}
+			fps = 0
+		case <-t.C:
+			// values are held until the next broadcast, nothing is logged before the first one
+			if !received {
+				continue
+			}
+			c.produceLogLine(lw, vars)
+			count++
+			c.CaptureCounter.Set(count)
+		}
+	}
+}
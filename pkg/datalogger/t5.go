@@ -0,0 +1,178 @@
+package datalogger
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+	"github.com/roffe/t7logger/pkg/t5"
+)
+
+type T5Client struct {
+	quitChan chan struct{}
+	Config
+}
+
+func NewT5(cfg Config) (*T5Client, error) {
+	return &T5Client{
+		quitChan: make(chan struct{}, 2),
+		Config:   cfg,
+	}, nil
+}
+
+func (c *T5Client) Close() {
+	c.quitChan <- struct{}{}
+	time.Sleep(200 * time.Millisecond)
+}
+
+func (c *T5Client) Write(v *kwp2000.VarDefinition, data []byte) error {
+	return fmt.Errorf("failed to write %s: writing RAM is not supported on T5", v.Name)
+}
+
+// t5Var is a variable and the RAM it is read from
+type t5Var struct {
+	v       *kwp2000.VarDefinition
+	address uint32
+}
+
+func (c *T5Client) Start() error {
+	lw, err := c.createLog()
+	if err != nil {
+		return err
+	}
+	defer lw.Close()
+
+	ctx := context.Background()
+
+	cl, err := gocan.New(ctx, c.Dev)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	t := t5.New(cl, c.T5Options...)
+
+	count := 0
+	errCount := 0
+	c.ErrorCounter.Set(errCount)
+
+	errPerSecond := 0
+	c.ErrorPerSecondCounter.Set(errPerSecond)
+
+	cps := 0
+	retries := 0
+	var vars []t5Var
+
+	err = retry.Do(func() error {
+		if err := t.StartSession(ctx); err != nil {
+			if retries == 0 {
+				return retry.Unrecoverable(err)
+			}
+			return err
+		}
+		c.OnMessage("Connected to ECU")
+
+		if vars == nil {
+			v, err := c.resolve(ctx, t)
+			if err != nil {
+				return retry.Unrecoverable(err)
+			}
+			vars = v
+		}
+
+		secondTicker := time.NewTicker(time.Second)
+		defer secondTicker.Stop()
+
+		tick := time.NewTicker(time.Second / time.Duration(c.Freq))
+		defer tick.Stop()
+
+		c.OnMessage(fmt.Sprintf("Live logging at %d fps", c.Freq))
+		for {
+			select {
+			case <-c.quitChan:
+				c.OnMessage("Stop logging...")
+				return nil
+			case <-secondTicker.C:
+				log.Println("cps:", cps)
+				cps = 0
+				c.ErrorPerSecondCounter.Set(errPerSecond)
+				if errPerSecond > 10 {
+					errPerSecond = 0
+					return fmt.Errorf("too many errors, restarting logging")
+				}
+				errPerSecond = 0
+			case <-tick.C:
+				if err := readT5Sample(ctx, t, vars); err != nil {
+					errCount++
+					errPerSecond++
+					c.ErrorCounter.Set(errCount)
+					c.OnMessage(fmt.Sprintf("Failed to read data: %v", err))
+					continue
+				}
+				c.produceLogLine(lw, c.Variables)
+				count++
+				cps++
+				c.CaptureCounter.Set(count)
+			}
+		}
+	},
+		retry.DelayType(retry.FixedDelay),
+		retry.Delay(500*time.Millisecond),
+		retry.Attempts(10),
+		retry.OnRetry(func(n uint, err error) {
+			retries++
+			c.OnMessage(fmt.Sprintf("Retry %d: %v", n, err))
+		}),
+	)
+	return err
+}
+
+// resolve looks up the RAM address of every variable by name in the symbol table of the ECU,
+// variables defined by address are read from there as is
+func (c *T5Client) resolve(ctx context.Context, t *t5.Client) ([]t5Var, error) {
+	c.OnMessage("Reading symbol table")
+	symbols, err := t.GetSymbolTable(ctx, c.OnMessage)
+	if err != nil {
+		return nil, err
+	}
+	c.OnMessage(fmt.Sprintf("Loaded %d symbols from ECU", len(symbols)))
+	vars := make([]t5Var, 0, len(c.Variables))
+outer:
+	for _, v := range c.Variables {
+		if v.Method == kwp2000.VAR_METHOD_ADDRESS {
+			vars = append(vars, t5Var{v: v, address: uint32(v.Value)})
+			continue
+		}
+		for _, sym := range symbols {
+			if sym.Name == v.Name {
+				if v.Length == 0 {
+					v.Length = sym.Length
+				}
+				vars = append(vars, t5Var{v: v, address: sym.Address})
+				continue outer
+			}
+		}
+		return nil, fmt.Errorf("%s not found in the symbol table of the ECU", v.Name)
+	}
+	return vars, nil
+}
+
+// readT5Sample reads every variable in one cycle, the variables only hold a complete sample if all reads succeed
+func readT5Sample(ctx context.Context, t *t5.Client, vars []t5Var) error {
+	data := make([][]byte, len(vars))
+	for i, tv := range vars {
+		b, err := t.ReadMemory(ctx, tv.address, int(tv.v.Length))
+		if err != nil {
+			return fmt.Errorf("%s: %w", tv.v.Name, err)
+		}
+		data[i] = b
+	}
+	for i, tv := range vars {
+		tv.v.Set(data[i])
+	}
+	return nil
+}
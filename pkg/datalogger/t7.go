@@ -1,31 +1,34 @@
 package datalogger
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/avast/retry-go/v4"
 	"github.com/roffe/gocan"
 	"github.com/roffe/t7logger/pkg/kwp2000"
-	"github.com/roffe/t7logger/pkg/sink"
 )
 
 type T7Client struct {
-	quitChan chan struct{}
-	out      strings.Builder
+	quitChan  chan struct{}
+	writeChan chan *writeRequest
 	Config
 }
 
+type writeRequest struct {
+	v    *kwp2000.VarDefinition
+	data []byte
+	err  chan error
+}
+
 func NewT7(cfg Config) (*T7Client, error) {
 	return &T7Client{
-		quitChan: make(chan struct{}, 2),
-		Config:   cfg,
+		quitChan:  make(chan struct{}, 2),
+		writeChan: make(chan *writeRequest),
+		Config:    cfg,
 	}, nil
 }
 
@@ -34,21 +37,22 @@ func (c *T7Client) Close() {
 	time.Sleep(200 * time.Millisecond)
 }
 
-func (c *T7Client) Start() error {
-	if _, err := os.Stat("logs"); os.IsNotExist(err) {
-		if err := os.Mkdir("logs", 0755); err != nil {
-			if err != os.ErrExist {
-				return fmt.Errorf("failed to create logs dir: %w", err)
-			}
-		}
+func (c *T7Client) Write(v *kwp2000.VarDefinition, data []byte) error {
+	req := &writeRequest{v: v, data: data, err: make(chan error, 1)}
+	select {
+	case c.writeChan <- req:
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("failed to write %s: logging is not running", v.Name)
 	}
-	filename := fmt.Sprintf("logs/log-%s.t7l", time.Now().Format("2006-01-02-15-04-05"))
-	c.OnMessage(fmt.Sprintf("Logging to %s", filename))
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	return <-req.err
+}
+
+func (c *T7Client) Start() error {
+	lw, err := c.createLog()
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return err
 	}
-	defer file.Close()
+	defer lw.Close()
 
 	ctx := context.Background()
 
@@ -71,7 +75,7 @@ func (c *T7Client) Start() error {
 	}
 	defer cl.Close()
 
-	kwp := kwp2000.New(cl)
+	kwp := kwp2000.New(cl, c.KWPOptions...)
 
 	count := 0
 	errCount := 0
@@ -83,8 +87,17 @@ func (c *T7Client) Start() error {
 	cps := 0
 	retries := 0
 
+	groups, err := kwp2000.SplitLocalIDs(c.Variables)
+	if err != nil {
+		return err
+	}
+	if len(groups) > 1 {
+		c.OnMessage(fmt.Sprintf("Logging %d symbols using %d local ids", len(c.Variables), len(groups)))
+	}
+	identified := false
+
 	err = retry.Do(func() error {
-		if err := kwp.StartSession(ctx, kwp2000.INIT_MSG_ID, kwp2000.INIT_RESP_ID); err != nil {
+		if err := kwp.StartSession(ctx); err != nil {
 			if retries == 0 {
 				return retry.Unrecoverable(err)
 			}
@@ -97,12 +110,29 @@ func (c *T7Client) Start() error {
 
 		c.OnMessage("Connected to ECU")
 
-		for i, v := range c.Variables {
-			//c.onMessage(fmt.Sprintf("%d %s %s %d %X", i, v.Name, v.Method, v.Value, v.Type))
-			if err := kwp.DynamicallyDefineLocalIdRequest(ctx, i, v); err != nil {
-				return fmt.Errorf("DynamicallyDefineLocalIdRequest: %w", err)
+		kaCtx, kaCancel := context.WithCancel(ctx)
+		defer kaCancel()
+		sessionLost := kwp.KeepAlive(kaCtx, time.Second)
+
+		if !identified {
+			if err := c.identify(ctx, kwp, lw); err != nil {
+				c.OnMessage(fmt.Sprintf("Failed to read ECU identification: %v", err))
+			}
+			identified = true
+		}
+
+		for _, g := range groups {
+			for i, v := range g.Variables {
+				//c.onMessage(fmt.Sprintf("%d %s %s %d %X", i, v.Name, v.Method, v.Value, v.Type))
+				if err := kwp.DefineLocalIdentifier(ctx, g.ID, i, v); err != nil {
+					// the ECU will keep refusing a definition it does not support, no point in reconnecting
+					if errors.Is(err, kwp2000.ErrServiceNotSupported) || errors.Is(err, kwp2000.ErrSubFunctionNotSupported) {
+						return retry.Unrecoverable(fmt.Errorf("failed to define %s: %w", v.Name, err))
+					}
+					return fmt.Errorf("DynamicallyDefineLocalIdRequest: %w", err)
+				}
+				time.Sleep(5 * time.Millisecond)
 			}
-			time.Sleep(5 * time.Millisecond)
 		}
 
 		secondTicker := time.NewTicker(time.Second)
@@ -117,6 +147,14 @@ func (c *T7Client) Start() error {
 			case <-c.quitChan:
 				c.OnMessage("Stop logging...")
 				return nil
+			case err, ok := <-sessionLost:
+				if ok {
+					return err
+				}
+				// closed without a loss, stop selecting on it
+				sessionLost = nil
+			case req := <-c.writeChan:
+				req.err <- c.write(ctx, kwp, req.v, req.data)
 			case <-secondTicker.C: // every time the ticker ticks
 				log.Println("cps:", cps)
 				cps = 0
@@ -127,7 +165,11 @@ func (c *T7Client) Start() error {
 				}
 				errPerSecond = 0
 			case <-t.C:
-				data, err := kwp.ReadDataByLocalIdentifier(ctx, 0xF0)
+				err := readSample(ctx, kwp, groups)
+				if errors.Is(err, kwp2000.ErrRequestOutOfRange) {
+					// the ECU has dropped the session and forgotten the definition, reconnect right away
+					return err
+				}
 				if err != nil {
 					errCount++
 					errPerSecond++
@@ -135,23 +177,7 @@ func (c *T7Client) Start() error {
 					c.OnMessage(fmt.Sprintf("Failed to read data: %v", err))
 					continue
 				}
-				r := bytes.NewReader(data)
-				for _, va := range c.Variables {
-					if err := va.Read(r); err != nil {
-						c.OnMessage(fmt.Sprintf("Failed to read %s: %v", va.Name, err))
-						break
-					}
-				}
-				if r.Len() > 0 {
-					left := r.Len()
-					leftovers := make([]byte, r.Len())
-					n, err := r.Read(leftovers)
-					if err != nil {
-						c.OnMessage(fmt.Sprintf("Failed to read leftovers: %v", err))
-					}
-					c.OnMessage(fmt.Sprintf("Leftovers %d: %X", left, leftovers[:n]))
-				}
-				c.produceLogLine(file, c.Variables)
+				c.produceLogLine(lw, c.Variables)
 				count++
 				cps++
 				c.CaptureCounter.Set(count)
@@ -169,16 +195,39 @@ func (c *T7Client) Start() error {
 	return err
 }
 
-func (c *T7Client) produceLogLine(file io.Writer, vars []*kwp2000.VarDefinition) {
-	c.out.WriteString("|")
-	var ms []string
-	for _, va := range vars {
-		c.out.WriteString(va.T7L() + "|")
-		ms = append(ms, va.Tuple())
+func (c *T7Client) write(ctx context.Context, kwp *kwp2000.Client, v *kwp2000.VarDefinition, data []byte) error {
+	granted, err := kwp.RequestSecurityAccess(ctx, false)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		return fmt.Errorf("failed to write %s: security access was not granted", v.Name)
+	}
+	return kwp.WriteVar(ctx, v, data)
+}
+
+// readSample reads every local id in one cycle, the variables only hold a complete sample if all reads succeed
+func readSample(ctx context.Context, kwp *kwp2000.Client, groups []*kwp2000.LocalIDGroup) error {
+	for _, g := range groups {
+		data, err := kwp.ReadDataByLocalIdentifier(ctx, g.ID)
+		if err != nil {
+			return err
+		}
+		if err := g.Read(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// identify reads the ECU identification and writes it as the log header
+func (c *T7Client) identify(ctx context.Context, kwp *kwp2000.Client, lw LogWriter) error {
+	ident, err := kwp.ReadECUIdentification(ctx)
+	if ident == nil {
+		return err
+	}
+	if err != nil {
+		c.OnMessage(fmt.Sprintf("Failed to read parts of the ECU identification: %v", err))
 	}
-	fmt.Fprintln(file, time.Now().Format("02-01-2006 15:04:05.999")+c.out.String()+"IMPORTANTLINE=0|")
-	c.Sink.Push(&sink.Message{
-		Data: []byte(time.Now().Format(ISO8601) + "|" + strings.Join(ms, ",")),
-	})
-	c.out.Reset()
+	return c.writeIdentification(lw, ident)
 }
@@ -0,0 +1,189 @@
+package datalogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+	"github.com/roffe/t7logger/pkg/t8"
+)
+
+type T8Client struct {
+	quitChan  chan struct{}
+	writeChan chan *writeRequest
+	Config
+}
+
+func NewT8(cfg Config) (*T8Client, error) {
+	return &T8Client{
+		quitChan:  make(chan struct{}, 2),
+		writeChan: make(chan *writeRequest),
+		Config:    cfg,
+	}, nil
+}
+
+func (c *T8Client) Close() {
+	c.quitChan <- struct{}{}
+	time.Sleep(200 * time.Millisecond)
+}
+
+func (c *T8Client) Write(v *kwp2000.VarDefinition, data []byte) error {
+	req := &writeRequest{v: v, data: data, err: make(chan error, 1)}
+	select {
+	case c.writeChan <- req:
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("failed to write %s: logging is not running", v.Name)
+	}
+	return <-req.err
+}
+
+func (c *T8Client) Start() error {
+	packets, err := t8.SplitPackets(c.Variables)
+	if err != nil {
+		return err
+	}
+
+	lw, err := c.createLog()
+	if err != nil {
+		return err
+	}
+	defer lw.Close()
+
+	ctx := context.Background()
+
+	cl, err := gocan.New(ctx, c.Dev)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	gm := t8.New(cl, c.T8Options...)
+
+	count := 0
+	errCount := 0
+	c.ErrorCounter.Set(errCount)
+
+	errPerSecond := 0
+	c.ErrorPerSecondCounter.Set(errPerSecond)
+
+	cps := 0
+	retries := 0
+	identified := false
+
+	err = retry.Do(func() error {
+		if err := gm.StartSession(ctx); err != nil {
+			if retries == 0 {
+				return retry.Unrecoverable(err)
+			}
+			return err
+		}
+		defer func() {
+			gm.StopSession(ctx)
+			time.Sleep(50 * time.Millisecond)
+		}()
+
+		c.OnMessage("Connected to ECU")
+
+		kaCtx, kaCancel := context.WithCancel(ctx)
+		defer kaCancel()
+		gm.KeepAlive(kaCtx, 2*time.Second)
+
+		if !identified {
+			if ident, err := gm.ReadECUIdentification(ctx); err != nil {
+				c.OnMessage(fmt.Sprintf("Failed to read ECU identification: %v", err))
+			} else if err := c.writeIdentification(lw, ident); err != nil {
+				return err
+			}
+			identified = true
+		}
+
+		// reading RAM by address is a secured service
+		granted, err := gm.RequestSecurityAccess(ctx)
+		if err != nil {
+			return err
+		}
+		if !granted {
+			return retry.Unrecoverable(errors.New("security access was not granted"))
+		}
+
+		for _, p := range packets.Packets {
+			if err := gm.DynamicallyDefineMessage(ctx, p.ID, p.Address, p.Size); err != nil {
+				if errors.Is(err, kwp2000.ErrServiceNotSupported) || errors.Is(err, kwp2000.ErrSubFunctionNotSupported) {
+					return retry.Unrecoverable(fmt.Errorf("failed to define %s: %w", p.Var.Name, err))
+				}
+				return err
+			}
+		}
+		ids := packets.IDs()
+
+		secondTicker := time.NewTicker(time.Second)
+		defer secondTicker.Stop()
+
+		t := time.NewTicker(time.Second / time.Duration(c.Freq))
+		defer t.Stop()
+
+		c.OnMessage(fmt.Sprintf("Live logging at %d fps", c.Freq))
+		for {
+			select {
+			case <-c.quitChan:
+				c.OnMessage("Stop logging...")
+				return nil
+			case req := <-c.writeChan:
+				req.err <- c.write(ctx, gm, req.v, req.data)
+			case <-secondTicker.C:
+				log.Println("cps:", cps)
+				cps = 0
+				c.ErrorPerSecondCounter.Set(errPerSecond)
+				if errPerSecond > 10 {
+					errPerSecond = 0
+					return fmt.Errorf("too many errors, restarting logging")
+				}
+				errPerSecond = 0
+			case <-t.C:
+				data, err := gm.ReadDataByPacketIdentifier(ctx, ids...)
+				if errors.Is(err, kwp2000.ErrRequestOutOfRange) {
+					// the ECU has been reset and forgotten the packets, reconnect right away
+					return err
+				}
+				if err == nil {
+					err = packets.Read(data)
+				}
+				if err != nil {
+					errCount++
+					errPerSecond++
+					c.ErrorCounter.Set(errCount)
+					c.OnMessage(fmt.Sprintf("Failed to read data: %v", err))
+					continue
+				}
+				c.produceLogLine(lw, c.Variables)
+				count++
+				cps++
+				c.CaptureCounter.Set(count)
+			}
+		}
+	},
+		retry.DelayType(retry.FixedDelay),
+		retry.Delay(500*time.Millisecond),
+		retry.Attempts(10),
+		retry.OnRetry(func(n uint, err error) {
+			retries++
+			c.OnMessage(fmt.Sprintf("Retry %d: %v", n, err))
+		}),
+	)
+	return err
+}
+
+func (c *T8Client) write(ctx context.Context, gm *t8.Client, v *kwp2000.VarDefinition, data []byte) error {
+	if v.Method != kwp2000.VAR_METHOD_ADDRESS {
+		return fmt.Errorf("failed to write %s: T8 can only write by address", v.Name)
+	}
+	if len(data) != int(v.Length) {
+		return fmt.Errorf("failed to write %s: expected %d bytes, got %d", v.Name, v.Length, len(data))
+	}
+	return gm.WriteMemoryByAddress(ctx, uint32(v.Value), data)
+}
@@ -0,0 +1,157 @@
+package dbc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/roffe/t7logger/pkg/broadcast"
+)
+
+// Message is a frame defined in a DBC file
+type Message struct {
+	ID          uint32
+	Name        string
+	Size        int
+	Transmitter string
+	Signals     []*broadcast.Signal
+}
+
+// File is a parsed DBC file, only messages and their signals are read
+type File struct {
+	Messages []*Message
+	// Skipped counts multiplexed signals, including extended multiplexing, which are not supported
+	Skipped int
+}
+
+// vectorIndependentID is the id of VECTOR__INDEPENDENT_SIG_MSG, the extended id flag with id 0x40000000
+const vectorIndependentID = 0xC0000000
+
+var (
+	messageRe = regexp.MustCompile(`^BO_\s+(\d+)\s+(\w+)\s*:\s*(\d+)\s+(\w+)`)
+	signalRe  = regexp.MustCompile(`^SG_\s+(\w+)\s*(M|m\d+M?)?\s*:\s*(\d+)\|(\d+)@([01])([+-])\s*\(([^,]+),([^)]+)\)\s*\[[^\]]*\]\s*"([^"]*)"`)
+)
+
+// Load parses the DBC file filename
+func Load(filename string) (*File, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("dbc: %w", err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads the messages and signals of a DBC file. Signals are named Message.Signal
+func Parse(r io.Reader) (*File, error) {
+	file := &File{}
+	var msg *Message
+	// signals of the pseudo message holding signals not sent in any frame are ignored
+	independent := false
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(text, "BO_ "):
+			m := messageRe.FindStringSubmatch(text)
+			if m == nil {
+				return nil, fmt.Errorf("dbc: line %d: invalid message %q", line, text)
+			}
+			id, err := strconv.ParseUint(m[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("dbc: line %d: %w", line, err)
+			}
+			independent = id == vectorIndependentID
+			if independent {
+				msg = nil
+				continue
+			}
+			size, _ := strconv.Atoi(m[3])
+			msg = &Message{
+				// bit 31 flags an extended id
+				ID:          uint32(id) & 0x1FFFFFFF,
+				Name:        m[2],
+				Size:        size,
+				Transmitter: m[4],
+			}
+			file.Messages = append(file.Messages, msg)
+		case strings.HasPrefix(text, "SG_ "):
+			if independent {
+				continue
+			}
+			if msg == nil {
+				return nil, fmt.Errorf("dbc: line %d: signal outside of a message", line)
+			}
+			sig, multiplexed, err := parseSignal(msg, text)
+			if err != nil {
+				return nil, fmt.Errorf("dbc: line %d: %w", line, err)
+			}
+			if multiplexed {
+				file.Skipped++
+				continue
+			}
+			msg.Signals = append(msg.Signals, sig)
+		case text == "":
+		default:
+			// a new keyword ends the signal list of the message
+			msg = nil
+			independent = false
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("dbc: %w", err)
+	}
+	return file, nil
+}
+
+func parseSignal(msg *Message, text string) (*broadcast.Signal, bool, error) {
+	m := signalRe.FindStringSubmatch(text)
+	if m == nil {
+		return nil, false, fmt.Errorf("invalid signal %q", text)
+	}
+	if strings.HasPrefix(m[2], "m") {
+		return nil, true, nil
+	}
+	start, _ := strconv.Atoi(m[3])
+	length, _ := strconv.Atoi(m[4])
+	if length < 1 || length > 64 {
+		return nil, false, fmt.Errorf("signal %s: invalid length %d", m[1], length)
+	}
+	if length > 32 {
+		return nil, false, fmt.Errorf("signal %s: %d bits, signals longer than 32 bits are not supported", m[1], length)
+	}
+	factor, err := strconv.ParseFloat(strings.TrimSpace(m[7]), 64)
+	if err != nil {
+		return nil, false, fmt.Errorf("signal %s: invalid factor: %w", m[1], err)
+	}
+	offset, err := strconv.ParseFloat(strings.TrimSpace(m[8]), 64)
+	if err != nil {
+		return nil, false, fmt.Errorf("signal %s: invalid offset: %w", m[1], err)
+	}
+	return &broadcast.Signal{
+		Name:         msg.Name + "." + m[1],
+		ID:           msg.ID,
+		StartBit:     start,
+		Length:       length,
+		LittleEndian: m[5] == "1",
+		Signed:       m[6] == "-",
+		Factor:       factor,
+		Offset:       offset,
+		Unit:         m[9],
+	}, false, nil
+}
+
+// Signals returns the signals of all messages, in file order
+func (f *File) Signals() []*broadcast.Signal {
+	var out []*broadcast.Signal
+	for _, m := range f.Messages {
+		out = append(out, m.Signals...)
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package dbc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/roffe/t7logger/pkg/broadcast"
+)
+
+func TestLoad(t *testing.T) {
+	f, err := Load("testdata/test.dbc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(f.Messages))
+	}
+
+	engine := f.Messages[0]
+	if engine.ID != 0x1A0 || engine.Name != "Engine" || engine.Size != 8 || engine.Transmitter != "ECU" {
+		t.Errorf("engine message %+v", engine)
+	}
+	// bit 31 flags the extended id 0x211
+	gearbox := f.Messages[1]
+	if gearbox.ID != 0x211 || gearbox.Name != "Gearbox" || gearbox.Size != 4 {
+		t.Errorf("gearbox message %+v", gearbox)
+	}
+
+	want := []broadcast.Signal{
+		{Name: "Engine.Speed", ID: 0x1A0, StartBit: 7, Length: 16, Factor: 1, Unit: "rpm"},
+		{Name: "Engine.Torque", ID: 0x1A0, StartBit: 23, Length: 16, Signed: true, Factor: 0.1, Offset: -10, Unit: "Nm"},
+		{Name: "Engine.Coolant", ID: 0x1A0, StartBit: 32, Length: 8, LittleEndian: true, Factor: 1, Offset: -40, Unit: "degC"},
+		// the multiplexer switch is a plain signal
+		{Name: "Gearbox.Mode", ID: 0x211, StartBit: 0, Length: 4, LittleEndian: true, Factor: 1},
+	}
+	got := f.Signals()
+	if len(got) != len(want) {
+		var names []string
+		for _, s := range got {
+			names = append(names, s.Name)
+		}
+		t.Fatalf("got signals %s", strings.Join(names, ","))
+	}
+	for i, s := range got {
+		if *s != want[i] {
+			t.Errorf("signal %d is %+v, want %+v", i, *s, want[i])
+		}
+	}
+	// m0, m1M and m2, the signals of VECTOR__INDEPENDENT_SIG_MSG are not counted
+	if f.Skipped != 3 {
+		t.Errorf("skipped %d signals, want 3", f.Skipped)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, text := range []string{
+		"SG_ Orphan : 0|8@1+ (1,0) [0|0] \"\" X",
+		"BO_ 100 A: 8 X\n SG_ Long : 0|40@1+ (1,0) [0|0] \"\" X",
+		"BO_ 100 A: 8 X\n SG_ Broken : 0|8@1+ (x,0) [0|0] \"\" X",
+		"BO_ A: 8 X",
+	} {
+		if _, err := Parse(strings.NewReader(text)); err == nil {
+			t.Errorf("%q parsed without error", text)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package ecu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+// TestActuator controls an output for duration or until ctx is done, whatever comes first.
+// Duration is capped to kwp2000.MaxActuatorDuration and control is always returned to the ECU
+func TestActuator(ctx context.Context, dev gocan.Adapter, a kwp2000.Actuator, state byte, duration time.Duration, cb func(string), opts ...kwp2000.Option) error {
+	if duration <= 0 || duration > kwp2000.MaxActuatorDuration {
+		duration = kwp2000.MaxActuatorDuration
+	}
+	// the session outlives ctx so control can be returned after a cancel
+	return withSession(context.Background(), dev, opts, func(k *kwp2000.Client) (err error) {
+		granted, err := k.RequestSecurityAccess(context.Background(), false)
+		if err != nil {
+			return err
+		}
+		if !granted {
+			return errors.New("security access was not granted")
+		}
+
+		defer func() {
+			if rerr := returnControl(k, a); rerr != nil {
+				cb(fmt.Sprintf("Failed to return %s to the ECU, it is released when the session ends: %v", a.Name, rerr))
+				if err == nil {
+					err = rerr
+				}
+				return
+			}
+			cb(fmt.Sprintf("%s returned to ECU control", a.Name))
+		}()
+
+		if err := k.ActivateOutput(ctx, a, state); err != nil {
+			return err
+		}
+		cb(fmt.Sprintf("%s activated for %s", a.Name, duration))
+
+		kaCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		lost := k.KeepAlive(kaCtx, time.Second)
+
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			cb(fmt.Sprintf("%s test stopped", a.Name))
+		case err, ok := <-lost:
+			if ok {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func returnControl(k *kwp2000.Client, a kwp2000.Actuator) error {
+	var err error
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err = k.ReturnControlToECU(ctx, a)
+		cancel()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
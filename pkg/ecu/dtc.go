@@ -0,0 +1,38 @@
+package ecu
+
+import (
+	"context"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+func ReadDTC(ctx context.Context, dev gocan.Adapter, opts ...kwp2000.Option) ([]kwp2000.DTC, error) {
+	var dtcs []kwp2000.DTC
+	err := withSession(ctx, dev, opts, func(k *kwp2000.Client) error {
+		var err error
+		dtcs, err = k.ReadDiagnosticTroubleCodesByStatus(ctx)
+		return err
+	})
+	return dtcs, err
+}
+
+// ReadFreezeFrames reads the stored DTCs and the freeze frame of each
+func ReadFreezeFrames(ctx context.Context, dev gocan.Adapter, opts ...kwp2000.Option) ([]*kwp2000.FreezeFrame, error) {
+	var frames []*kwp2000.FreezeFrame
+	err := withSession(ctx, dev, opts, func(k *kwp2000.Client) error {
+		dtcs, err := k.ReadDiagnosticTroubleCodesByStatus(ctx)
+		if err != nil {
+			return err
+		}
+		frames, err = k.ReadFreezeFrames(ctx, dtcs)
+		return err
+	})
+	return frames, err
+}
+
+func ClearDTC(ctx context.Context, dev gocan.Adapter, opts ...kwp2000.Option) error {
+	return withSession(ctx, dev, opts, func(k *kwp2000.Client) error {
+		return k.ClearDiagnosticInformation(ctx)
+	})
+}
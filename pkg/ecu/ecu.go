@@ -14,7 +14,7 @@ import (
 	"github.com/roffe/t7logger/pkg/symbol"
 )
 
-func GetSymbols(ctx context.Context, dev gocan.Adapter, cb func(string)) ([]*symbol.Symbol, error) {
+func GetSymbols(ctx context.Context, dev gocan.Adapter, cb func(string), opts ...kwp2000.Option) ([]*symbol.Symbol, error) {
 	/*
 		logger := func(s string) {
 			log.Println(s)
@@ -44,8 +44,8 @@ func GetSymbols(ctx context.Context, dev gocan.Adapter, cb func(string)) ([]*sym
 	}
 	defer cl.Close()
 
-	k := kwp2000.New(cl)
-	if err := k.StartSession(ctx, kwp2000.INIT_MSG_ID, kwp2000.INIT_RESP_ID); err != nil {
+	k := kwp2000.New(cl, opts...)
+	if err := k.StartSession(ctx); err != nil {
 		return nil, err
 	}
 	defer k.StopSession(ctx)
@@ -132,3 +132,20 @@ func GetSymbols(ctx context.Context, dev gocan.Adapter, cb func(string)) ([]*sym
 
 	return symbols, nil
 }
+
+// withSession connects to the ECU and runs fn within a KWP session
+func withSession(ctx context.Context, dev gocan.Adapter, opts []kwp2000.Option, fn func(*kwp2000.Client) error) error {
+	cl, err := gocan.New(context.TODO(), dev)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	k := kwp2000.New(cl, opts...)
+	if err := k.StartSession(ctx); err != nil {
+		return err
+	}
+	defer k.StopSession(ctx)
+
+	return fn(k)
+}
@@ -0,0 +1,133 @@
+package ecu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+const (
+	// bytes read between progress updates and writes to the partial file, a multiple of the 0xF5 ReadFlash block
+	flashChunkSize = 0xF5 * 8
+	// reconnects in a row without progress before the dump is given up
+	flashReconnects = 5
+)
+
+// FlashProgress is reported after every chunk read
+type FlashProgress struct {
+	Done  int
+	Total int
+	ETA   time.Duration
+}
+
+// DumpFlash reads the full flash to filename. Blocks are written to filename.part as they are read,
+// a dropped connection is reconnected and the dump resumes from the last good block, as does
+// a new call if the partial file is left from an earlier attempt. The result is verified against
+// the footer before it is renamed to filename
+func DumpFlash(ctx context.Context, dev gocan.Adapter, filename string, cb func(string), progress func(FlashProgress), opts ...kwp2000.Option) error {
+	part, err := os.OpenFile(filename+".part", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("DumpFlash: %w", err)
+	}
+	defer part.Close()
+
+	fi, err := part.Stat()
+	if err != nil {
+		return fmt.Errorf("DumpFlash: %w", err)
+	}
+	pos := int(fi.Size()) / flashChunkSize * flashChunkSize
+	if pos > FlashSize {
+		pos = 0
+	}
+	if pos > 0 {
+		cb(fmt.Sprintf("Resuming flash dump from 0x%X", pos))
+	}
+
+	cl, err := gocan.New(context.TODO(), dev)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	k := kwp2000.New(cl, opts...)
+
+	if err := connectFlash(ctx, k); err != nil {
+		return err
+	}
+	defer k.StopSession(ctx)
+
+	ident, err := k.ReadECUIdentification(ctx)
+	if err != nil {
+		// the check skips whatever could not be read
+		cb(fmt.Sprintf("Failed to read ECU identification, skipping those checks: %v", err))
+	}
+
+	start, startPos := time.Now(), pos
+	reconnects := 0
+	for pos < FlashSize {
+		length := flashChunkSize
+		if FlashSize-pos < length {
+			length = FlashSize - pos
+		}
+		b, err := k.ReadFlash(ctx, pos, length)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if reconnects++; reconnects > flashReconnects {
+				return fmt.Errorf("DumpFlash: giving up at 0x%X: %w", pos, err)
+			}
+			cb(fmt.Sprintf("Read failed at 0x%X, reconnecting: %v", pos, err))
+			k.StopSession(ctx)
+			time.Sleep(time.Second)
+			if err := connectFlash(ctx, k); err != nil {
+				cb(fmt.Sprintf("Reconnect failed: %v", err))
+			}
+			continue
+		}
+		reconnects = 0
+		if _, err := part.WriteAt(b, int64(pos)); err != nil {
+			return fmt.Errorf("DumpFlash: %w", err)
+		}
+		pos += len(b)
+
+		p := FlashProgress{Done: pos, Total: FlashSize}
+		if elapsed := time.Since(start); pos > startPos {
+			p.ETA = time.Duration(float64(elapsed) / float64(pos-startPos) * float64(FlashSize-pos))
+		}
+		progress(p)
+	}
+
+	bin := make([]byte, FlashSize)
+	if _, err := part.ReadAt(bin, 0); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("DumpFlash: %w", err)
+	}
+	if err := VerifyFlash(bin, ident); err != nil {
+		return fmt.Errorf("%w, the dump is kept as %s.part, delete it to read again", err, filename)
+	}
+	part.Close()
+	if err := os.Rename(filename+".part", filename); err != nil {
+		return fmt.Errorf("DumpFlash: %w", err)
+	}
+	cb(fmt.Sprintf("Flash saved to %s in %s", filename, time.Since(start).Round(time.Second)))
+	return nil
+}
+
+func connectFlash(ctx context.Context, k *kwp2000.Client) error {
+	if err := k.StartSession(ctx); err != nil {
+		return err
+	}
+	granted, err := k.RequestSecurityAccess(ctx, true)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		return errors.New("security access not granted")
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package ecu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+// Trionic 7 footer field ids
+const (
+	FOOTER_VIN              = 0x90
+	FOOTER_IMMO_CODE        = 0x92
+	FOOTER_PART_NUMBER      = 0x94
+	FOOTER_SOFTWARE_VERSION = 0x95
+	FOOTER_CAR_DESCRIPTION  = 0x97
+	FOOTER_SYMBOL_TABLE     = 0x9B
+	FOOTER_CHECKSUM_F2      = 0xF2
+	FOOTER_CHECKSUM_FB      = 0xFB
+	FOOTER_FLASH_BOTTOM     = 0xFC
+	FOOTER_FLASH_LAST       = 0xFD
+	FOOTER_FW_LENGTH        = 0xFE
+)
+
+const (
+	FlashSize  = 0x80000
+	footerSize = 0x100
+)
+
+// Footer is the field table at the end of the flash, fields are stored backwards from the
+// last byte as [data][id][length]
+type Footer map[byte][]byte
+
+// ParseFooter reads the footer of a flash image
+func ParseFooter(bin []byte) (Footer, error) {
+	if len(bin) < footerSize {
+		return nil, errors.New("ParseFooter: binary too small")
+	}
+	footer := make(Footer)
+	pos := len(bin) - 1
+	for pos > len(bin)-footerSize {
+		length, id := int(bin[pos]), bin[pos-1]
+		if id == 0xFF || id == 0x00 {
+			break
+		}
+		if length == 0 || length >= 0x30 || pos-1-length < len(bin)-footerSize {
+			return nil, fmt.Errorf("ParseFooter: invalid field 0x%02X length %d at 0x%X", id, length, pos)
+		}
+		footer[id] = bin[pos-1-length : pos-1]
+		pos -= 2 + length
+	}
+	if len(footer) == 0 {
+		return nil, errors.New("ParseFooter: no footer found")
+	}
+	return footer, nil
+}
+
+// String returns a text field, text is stored reversed
+func (f Footer) String(id byte) string {
+	data := f[id]
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[len(data)-1-i] = b
+	}
+	return strings.TrimSpace(string(bytes.Trim(out, "\x00\xFF")))
+}
+
+// Address returns a pointer field
+func (f Footer) Address(id byte) (int, bool) {
+	data, ok := f[id]
+	if !ok || len(data) > 4 {
+		return 0, false
+	}
+	var val int
+	for _, b := range data {
+		val = val<<8 | int(b)
+	}
+	return val, true
+}
+
+// ChecksumFB is the sum of every byte of the firmware
+func ChecksumFB(fw []byte) uint32 {
+	var sum uint32
+	for _, b := range fw {
+		sum += uint32(b)
+	}
+	return sum
+}
+
+var checksumF2Xor = [8]uint32{0x81184224, 0x24421881, 0xc33c6666, 0x3cc3c3c3, 0x11882244, 0x18241824, 0x84211248, 0x12345678}
+
+// ChecksumF2 is the sum of the big endian words of the firmware xored with a rotating table,
+// only newer binaries store it in the footer
+func ChecksumF2(fw []byte) uint32 {
+	var sum uint32
+	x := 1
+	for i := 0; i+4 <= len(fw); i += 4 {
+		sum += binary.BigEndian.Uint32(fw[i:]) ^ checksumF2Xor[x]
+		x = (x + 1) % len(checksumF2Xor)
+	}
+	sum ^= 0x40314081
+	sum -= 0x7FEFDFD0
+	return sum
+}
+
+// verifyChecksums compares the checksums in the footer with the ones computed over the firmware,
+// the same way T7Suite checks a binary
+func verifyChecksums(bin []byte, footer Footer) error {
+	length, ok := footer.Address(FOOTER_FW_LENGTH)
+	if !ok || length == 0 || length > len(bin) {
+		return fmt.Errorf("invalid firmware length %X", footer[FOOTER_FW_LENGTH])
+	}
+	fw := bin[:length]
+	for _, c := range []struct {
+		id  byte
+		sum func([]byte) uint32
+	}{
+		{FOOTER_CHECKSUM_FB, ChecksumFB},
+		{FOOTER_CHECKSUM_F2, ChecksumF2},
+	} {
+		if _, ok := footer[c.id]; !ok {
+			continue
+		}
+		stored, ok := footer.Address(c.id)
+		if !ok {
+			return fmt.Errorf("invalid checksum field 0x%02X: %X", c.id, footer[c.id])
+		}
+		if sum := c.sum(fw); uint32(stored) != sum {
+			return fmt.Errorf("checksum 0x%02X is %08X, the firmware sums to %08X", c.id, stored, sum)
+		}
+	}
+	return nil
+}
+
+// VerifyFlash checks a full flash dump against the footer and its checksums, and against the
+// identification the ECU reported if not nil
+func VerifyFlash(bin []byte, ident *kwp2000.ECUIdentification) error {
+	if len(bin) != FlashSize {
+		return fmt.Errorf("VerifyFlash: expected %d bytes, got %d", FlashSize, len(bin))
+	}
+	footer, err := ParseFooter(bin)
+	if err != nil {
+		return fmt.Errorf("VerifyFlash: %w", err)
+	}
+	for _, id := range []byte{FOOTER_VIN, FOOTER_SOFTWARE_VERSION, FOOTER_SYMBOL_TABLE, FOOTER_FW_LENGTH, FOOTER_CHECKSUM_FB} {
+		if _, ok := footer[id]; !ok {
+			return fmt.Errorf("VerifyFlash: footer field 0x%02X missing", id)
+		}
+	}
+	for _, id := range []byte{FOOTER_SYMBOL_TABLE, FOOTER_FLASH_BOTTOM, FOOTER_FLASH_LAST} {
+		if _, ok := footer[id]; !ok {
+			continue
+		}
+		addr, ok := footer.Address(id)
+		if !ok || addr >= FlashSize {
+			return fmt.Errorf("VerifyFlash: footer field 0x%02X points outside of flash: %X", id, footer[id])
+		}
+	}
+	if err := verifyChecksums(bin, footer); err != nil {
+		return fmt.Errorf("VerifyFlash: %w", err)
+	}
+	if ident != nil {
+		if vin := footer.String(FOOTER_VIN); ident.VIN != "" && vin != ident.VIN {
+			return fmt.Errorf("VerifyFlash: footer VIN %q does not match ECU VIN %q", vin, ident.VIN)
+		}
+		if sw := footer.String(FOOTER_SOFTWARE_VERSION); ident.SoftwareVersion != "" && sw != ident.SoftwareVersion {
+			return fmt.Errorf("VerifyFlash: footer software version %q does not match ECU %q", sw, ident.SoftwareVersion)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package ecu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+const testFWLength = 0x7FF00
+
+// testFlash builds a flash image with a footer holding the given fields, numbers are stored big endian
+// and text reversed
+func testFlash(fields map[byte][]byte) []byte {
+	bin := make([]byte, FlashSize)
+	for i := range bin[:testFWLength] {
+		bin[i] = byte(i*7 + i>>8)
+	}
+	for i := range bin[testFWLength:] {
+		bin[testFWLength+i] = 0xFF
+	}
+	pos := FlashSize - 1
+	for _, id := range []byte{FOOTER_VIN, FOOTER_SOFTWARE_VERSION, FOOTER_SYMBOL_TABLE, FOOTER_FW_LENGTH, FOOTER_CHECKSUM_FB, FOOTER_CHECKSUM_F2} {
+		data, ok := fields[id]
+		if !ok {
+			continue
+		}
+		bin[pos] = byte(len(data))
+		bin[pos-1] = id
+		copy(bin[pos-1-len(data):], data)
+		pos -= 2 + len(data)
+	}
+	return bin
+}
+
+func reversed(s string) []byte {
+	out := []byte(s)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+func be32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+// checksums of the firmware of testFlash, computed with a separate implementation of the algorithms
+// so the checksum functions aren't checked against themselves. They are not taken from a real binary
+const (
+	testChecksumFB = 0x03FB8080
+	testChecksumF2 = 0x36F12841
+)
+
+func testFields() map[byte][]byte {
+	return map[byte][]byte{
+		FOOTER_VIN:              reversed("YS3FH41U571000001"),
+		FOOTER_SOFTWARE_VERSION: reversed("EU0AF01C.55P"),
+		FOOTER_SYMBOL_TABLE:     be32(0x40000),
+		FOOTER_FW_LENGTH:        be32(testFWLength),
+		FOOTER_CHECKSUM_FB:      be32(testChecksumFB),
+		FOOTER_CHECKSUM_F2:      be32(testChecksumF2),
+	}
+}
+
+func TestVerifyFlash(t *testing.T) {
+	ident := &kwp2000.ECUIdentification{VIN: "YS3FH41U571000001", SoftwareVersion: "EU0AF01C.55P"}
+	if err := VerifyFlash(testFlash(testFields()), ident); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := testFields()
+	delete(fields, FOOTER_CHECKSUM_F2)
+	if err := VerifyFlash(testFlash(fields), nil); err != nil {
+		t.Errorf("without F2 checksum: %v", err)
+	}
+
+	if err := VerifyFlash(testFlash(testFields()), &kwp2000.ECUIdentification{VIN: "YS3FH41U571000002"}); err == nil || !strings.Contains(err.Error(), "VIN") {
+		t.Errorf("VIN mismatch: got %v", err)
+	}
+}
+
+func TestVerifyFlashChecksumMismatch(t *testing.T) {
+	for _, id := range []byte{FOOTER_CHECKSUM_FB, FOOTER_CHECKSUM_F2} {
+		fields := testFields()
+		if id == FOOTER_CHECKSUM_FB {
+			delete(fields, FOOTER_CHECKSUM_F2)
+		}
+		bin := testFlash(fields)
+		bin[0x1234]++
+		if err := VerifyFlash(bin, nil); err == nil || !strings.Contains(err.Error(), "checksum") {
+			t.Errorf("changed firmware with checksum 0x%02X: got %v", id, err)
+		}
+	}
+
+	fields := testFields()
+	delete(fields, FOOTER_CHECKSUM_FB)
+	if err := VerifyFlash(testFlash(fields), nil); err == nil {
+		t.Error("missing FB checksum was accepted")
+	}
+}
+
+func TestChecksumFB(t *testing.T) {
+	tests := []struct {
+		fw   []byte
+		want uint32
+	}{
+		{nil, 0},
+		{[]byte{0x01, 0x02, 0x03}, 6},
+		{bytes.Repeat([]byte{0xFF}, 0x80000), 0x07F80000},
+	}
+	for _, tt := range tests {
+		if got := ChecksumFB(tt.fw); got != tt.want {
+			t.Errorf("%d bytes: got %08X, want %08X", len(tt.fw), got, tt.want)
+		}
+	}
+}
+
+func TestChecksumF2(t *testing.T) {
+	tests := []struct {
+		name string
+		fw   []byte
+		want uint32
+	}{
+		// 0 ^ 0x40314081 - 0x7FEFDFD0
+		{"empty", nil, 0xC04160B1},
+		// the first word is xored with the second entry of the table, 0x24421881
+		{"one word", make([]byte, 4), 0xE4837830},
+		{"partial word ignored", make([]byte, 5), 0xE4837830},
+		{"one word set", []byte{0x01, 0x02, 0x03, 0x04}, 0xE5817B34},
+		// the table wraps to its first entry at the eighth word and to the second at the ninth
+		{"eight words", make([]byte, 32), 0xA57D87A7},
+		{"nine words", make([]byte, 36), 0x49BF2126},
+	}
+	for _, tt := range tests {
+		if got := ChecksumF2(tt.fw); got != tt.want {
+			t.Errorf("%s: got %08X, want %08X", tt.name, got, tt.want)
+		}
+	}
+	if got := ChecksumF2(testFlash(nil)[:testFWLength]); got != testChecksumF2 {
+		t.Errorf("test flash: got %08X, want %08X", got, testChecksumF2)
+	}
+	if got := ChecksumFB(testFlash(nil)[:testFWLength]); got != testChecksumFB {
+		t.Errorf("test flash FB: got %08X, want %08X", got, testChecksumFB)
+	}
+}
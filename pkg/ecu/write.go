@@ -0,0 +1,47 @@
+package ecu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+	"github.com/roffe/t7logger/pkg/symbol"
+)
+
+// ResolveAddress returns v defined by address if it is defined by symbol number, the address is
+// looked up in symbols which has to be the symbol table of the ECU written to
+func ResolveAddress(v *kwp2000.VarDefinition, symbols []*symbol.Symbol) (*kwp2000.VarDefinition, error) {
+	if v.Method != kwp2000.VAR_METHOD_SYMBOL {
+		return v, nil
+	}
+	if v.Value < 0 || v.Value >= len(symbols) {
+		return nil, fmt.Errorf("symbol #%d of %s is not in the symbol table", v.Value, v.Name)
+	}
+	sym := symbols[v.Value]
+	if sym.Name != v.Name {
+		return nil, fmt.Errorf("symbol #%d is %s in the symbol table, not %s", v.Value, sym.Name, v.Name)
+	}
+	if sym.Length != v.Length {
+		return nil, fmt.Errorf("%s is %d bytes in the symbol table, not %d", v.Name, sym.Length, v.Length)
+	}
+	out := *v
+	out.Method = kwp2000.VAR_METHOD_ADDRESS
+	out.Value = int(sym.Address)
+	return &out, nil
+}
+
+// WriteVar writes raw data to a variable in RAM, security access is requested first
+func WriteVar(ctx context.Context, dev gocan.Adapter, v *kwp2000.VarDefinition, data []byte, opts ...kwp2000.Option) error {
+	return withSession(ctx, dev, opts, func(k *kwp2000.Client) error {
+		granted, err := k.RequestSecurityAccess(ctx, false)
+		if err != nil {
+			return err
+		}
+		if !granted {
+			return errors.New("security access was not granted")
+		}
+		return k.WriteVar(ctx, v, data)
+	})
+}
@@ -0,0 +1,135 @@
+package kwp2000
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// IsString reports whether the variable is a CHAR array decoded as text
+func (v *VarDefinition) IsString() bool {
+	return v.Type&CHAR != 0 && v.Length > 1
+}
+
+// IsRaw reports whether the variable is a STRUCT logged as its raw bytes, the layout of the members is not known
+func (v *VarDefinition) IsRaw() bool {
+	return v.Type&STRUCT != 0 && !v.IsString() && !v.IsBitfield()
+}
+
+// RawString returns the bytes of a raw variable in hex, empty if nothing has been read
+func (v *VarDefinition) RawString() string {
+	return fmt.Sprintf("% X", v.data)
+}
+
+// IsArray reports whether the variable decodes into indexed channels, one per element
+func (v *VarDefinition) IsArray() bool {
+	if v.IsString() || v.IsBitfield() || v.IsRaw() {
+		return false
+	}
+	if v.ElementSize != 0 {
+		return v.ElementSize < v.Length
+	}
+	return v.Length != 1 && v.Length != 2 && v.Length != 4
+}
+
+// elementSize returns the size of one array element, from ElementSize if set or guessed from the type flags
+func (v *VarDefinition) elementSize() int {
+	switch {
+	case v.ElementSize != 0:
+		return int(v.ElementSize)
+	case v.Type&CHAR != 0:
+		return 1
+	case v.Type&LONG != 0 && v.Length%4 == 0:
+		return 4
+	case v.Length%2 == 0:
+		return 2
+	}
+	return 1
+}
+
+// Elements splits an array into one variable per element, named Name[i]
+func (v *VarDefinition) Elements() []*VarDefinition {
+	size := v.elementSize()
+	var out []*VarDefinition
+	for i := 0; (i+1)*size <= int(v.Length); i++ {
+		e := &VarDefinition{
+			Name:             fmt.Sprintf("%s[%d]", v.Name, i),
+			Method:           v.Method,
+			Value:            v.Value,
+			Type:             v.Type &^ (STRUCT | CHAR),
+			Length:           uint16(size),
+			Unit:             v.Unit,
+			Correctionfactor: v.Correctionfactor,
+		}
+		if len(v.data) == int(v.Length) {
+			e.data = v.data[i*size : (i+1)*size]
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// Text returns the value of a CHAR array up to the first NUL
+func (v *VarDefinition) Text() string {
+	data := v.data
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	// keep the log and sink separators out of the text
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		switch {
+		case r == '|' || r == ',' || r == ':' || r == '=':
+			return ' '
+		case r < 0x20 || r > 0x7E:
+			return '.'
+		}
+		return r
+	}, string(data)))
+}
+
+// Channels returns the name and sink id of every channel the variable decodes into
+func (v *VarDefinition) Channels() (names, ids []string) {
+	switch {
+	case v.IsBitfield():
+		return v.BitNames(), v.BitIDs()
+	case v.IsArray():
+		for i, e := range v.Elements() {
+			names = append(names, e.Name)
+			ids = append(ids, fmt.Sprintf("%d[%d]", v.Value, i))
+		}
+		return names, ids
+	}
+	return []string{v.Name}, []string{fmt.Sprintf("%d", v.Value)}
+}
+
+// arrayString formats every element as name=value joined by sep, with the sink id instead of name if ids is set
+func (v *VarDefinition) arrayString(sep string, ids bool) string {
+	var out []string
+	for i, e := range v.Elements() {
+		if ids {
+			out = append(out, fmt.Sprintf("%d[%d]:%s", v.Value, i, e.StringValue()))
+			continue
+		}
+		out = append(out, e.T7L())
+	}
+	return strings.Join(out, sep)
+}
+
+// Values returns the value of every channel in the order of Channels, with the correction factor applied
+func (v *VarDefinition) Values() []string {
+	switch {
+	case v.IsBitfield():
+		var out []string
+		for _, b := range v.Bits() {
+			out = append(out, fmt.Sprint(boolValue(b)))
+		}
+		return out
+	case v.IsArray():
+		var out []string
+		for _, e := range v.Elements() {
+			out = append(out, e.StringValue())
+		}
+		return out
+	}
+	return []string{v.StringValue()}
+}
@@ -0,0 +1,51 @@
+package kwp2000
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStructIsRaw(t *testing.T) {
+	v := &VarDefinition{Name: "Adapt.Struct", Value: 7, Length: 6, Type: STRUCT}
+	if !v.IsRaw() || v.IsArray() {
+		t.Fatalf("STRUCT raw %v array %v, want a single raw value", v.IsRaw(), v.IsArray())
+	}
+	if got := v.StringValue(); got != "" {
+		t.Errorf("unread value %q", got)
+	}
+	v.Set([]byte{0x01, 0x02, 0x00, 0xFF, 0x10, 0x80})
+	names, ids := v.Channels()
+	if !reflect.DeepEqual(names, []string{"Adapt.Struct"}) || !reflect.DeepEqual(ids, []string{"7"}) {
+		t.Errorf("channels %v %v", names, ids)
+	}
+	if got := v.Values(); !reflect.DeepEqual(got, []string{"01 02 00 FF 10 80"}) {
+		t.Errorf("values %v", got)
+	}
+	if got := v.T7L(); got != "Adapt.Struct=01 02 00 FF 10 80" {
+		t.Errorf("T7L %q", got)
+	}
+	if got := v.Tuple(); got != "7:01 02 00 FF 10 80" {
+		t.Errorf("Tuple %q", got)
+	}
+
+	// a CHAR STRUCT is still text
+	text := &VarDefinition{Name: "Text", Length: 3, Type: STRUCT | CHAR}
+	if text.IsRaw() || !text.IsString() {
+		t.Error("CHAR STRUCT is not text")
+	}
+}
+
+func TestArrayElements(t *testing.T) {
+	v := &VarDefinition{Name: "Table", Value: 3, Length: 6, Type: SIGNED}
+	v.Set([]byte{0x00, 0x01, 0xFF, 0xFE, 0x01, 0x00})
+	if !v.IsArray() {
+		t.Fatal("6 byte variable is not an array")
+	}
+	names, ids := v.Channels()
+	if !reflect.DeepEqual(names, []string{"Table[0]", "Table[1]", "Table[2]"}) || !reflect.DeepEqual(ids, []string{"3[0]", "3[1]", "3[2]"}) {
+		t.Errorf("channels %v %v", names, ids)
+	}
+	if got := v.Values(); !reflect.DeepEqual(got, []string{"1", "-2", "256"}) {
+		t.Errorf("values %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+package kwp2000
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// IsBitfield reports whether the variable decodes into boolean channels instead of a number
+func (v *VarDefinition) IsBitfield() bool {
+	return v.Mask != 0 || v.Type&BITFIELD != 0
+}
+
+// bitNumbers returns the bits set in the mask, every bit of the value if there is no mask.
+// Bits are numbered from the lsb of the last byte so values of any length work
+func (v *VarDefinition) bitNumbers() []int {
+	var out []int
+	if v.Mask == 0 {
+		for bit := 0; bit < int(v.Length)*8; bit++ {
+			out = append(out, bit)
+		}
+		return out
+	}
+	for bit := 0; bit < 16 && bit < int(v.Length)*8; bit++ {
+		if v.Mask&(1<<bit) != 0 {
+			out = append(out, bit)
+		}
+	}
+	return out
+}
+
+// BitNames returns the channel name of every bit, a mask with a single bit keeps the variable name
+func (v *VarDefinition) BitNames() []string {
+	if bits.OnesCount16(v.Mask) == 1 {
+		return []string{v.Name}
+	}
+	var out []string
+	for _, bit := range v.bitNumbers() {
+		out = append(out, fmt.Sprintf("%s.Bit%d", v.Name, bit))
+	}
+	return out
+}
+
+// Bits returns the state of every bit in the order of BitNames
+func (v *VarDefinition) Bits() []bool {
+	var out []bool
+	for _, bit := range v.bitNumbers() {
+		out = append(out, v.bit(bit))
+	}
+	return out
+}
+
+// BitIDs returns the sink channel id of every bit in the order of BitNames
+func (v *VarDefinition) BitIDs() []string {
+	if bits.OnesCount16(v.Mask) == 1 {
+		return []string{fmt.Sprintf("%d", v.Value)}
+	}
+	var out []string
+	for _, bit := range v.bitNumbers() {
+		out = append(out, fmt.Sprintf("%d.%d", v.Value, bit))
+	}
+	return out
+}
+
+// bit returns bit n of the big endian data, false if the data does not match the length
+func (v *VarDefinition) bit(n int) bool {
+	if len(v.data) != int(v.Length) || n/8 >= len(v.data) {
+		return false
+	}
+	return v.data[len(v.data)-1-n/8]&(1<<(n%8)) != 0
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// bitfieldString formats every bit as name=value joined by sep, with id instead of name if ids is set
+func (v *VarDefinition) bitfieldString(sep string, ids bool) string {
+	names := v.BitNames()
+	if ids {
+		names = v.BitIDs()
+	}
+	format := "%s=%d"
+	if ids {
+		format = "%s:%d"
+	}
+	var out []string
+	for i, b := range v.Bits() {
+		out = append(out, fmt.Sprintf(format, names[i], boolValue(b)))
+	}
+	return strings.Join(out, sep)
+}
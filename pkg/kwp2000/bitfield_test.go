@@ -0,0 +1,79 @@
+package kwp2000
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBitfield(t *testing.T) {
+	tests := []struct {
+		name  string
+		v     *VarDefinition
+		data  []byte
+		names []string
+		bits  []bool
+	}{
+		{
+			name:  "mask",
+			v:     &VarDefinition{Name: "Flags", Length: 2, Mask: 0x8001},
+			data:  []byte{0x80, 0x00},
+			names: []string{"Flags.Bit0", "Flags.Bit15"},
+			bits:  []bool{false, true},
+		},
+		{
+			name:  "single bit mask",
+			v:     &VarDefinition{Name: "Flag", Length: 1, Mask: 0x04},
+			data:  []byte{0x04},
+			names: []string{"Flag"},
+			bits:  []bool{true},
+		},
+		{
+			name:  "mask wider than the value",
+			v:     &VarDefinition{Name: "Flags", Length: 1, Mask: 0x0101},
+			data:  []byte{0x01},
+			names: []string{"Flags.Bit0"},
+			bits:  []bool{true},
+		},
+		{
+			name:  "no mask",
+			v:     &VarDefinition{Name: "Flags", Length: 1, Type: BITFIELD},
+			data:  []byte{0x81},
+			names: []string{"Flags.Bit0", "Flags.Bit1", "Flags.Bit2", "Flags.Bit3", "Flags.Bit4", "Flags.Bit5", "Flags.Bit6", "Flags.Bit7"},
+			bits:  []bool{true, false, false, false, false, false, false, true},
+		},
+		{
+			name:  "data of the wrong length",
+			v:     &VarDefinition{Name: "Flags", Length: 2, Mask: 0x0003},
+			data:  []byte{0xFF},
+			names: []string{"Flags.Bit0", "Flags.Bit1"},
+			bits:  []bool{false, false},
+		},
+	}
+	for _, tt := range tests {
+		tt.v.Set(tt.data)
+		if names := tt.v.BitNames(); !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("%s: names %v, want %v", tt.name, names, tt.names)
+		}
+		if bits := tt.v.Bits(); !reflect.DeepEqual(bits, tt.bits) {
+			t.Errorf("%s: bits %v, want %v", tt.name, bits, tt.bits)
+		}
+	}
+}
+
+func TestBitfieldOverLength(t *testing.T) {
+	// longer than 4 bytes, every bit is still its own channel
+	v := &VarDefinition{Name: "Flags", Length: 6, Type: BITFIELD}
+	v.Set([]byte{0x80, 0x00, 0x00, 0x00, 0x01, 0x01})
+	bits := v.Bits()
+	if len(bits) != 48 || len(v.BitNames()) != 48 {
+		t.Fatalf("got %d bits and %d names, want 48", len(bits), len(v.BitNames()))
+	}
+	for i, b := range bits {
+		if want := i == 0 || i == 8 || i == 47; b != want {
+			t.Errorf("bit %d is %v, want %v", i, b, want)
+		}
+	}
+	if got := v.BitNames()[47]; got != "Flags.Bit47" {
+		t.Errorf("last bit named %s", got)
+	}
+}
@@ -0,0 +1,91 @@
+package kwp2000
+
+import (
+	"context"
+	"fmt"
+)
+
+// DTC is a diagnostic trouble code as reported by ReadDiagnosticTroubleCodesByStatus
+type DTC struct {
+	Code   uint16
+	Status byte
+}
+
+// String returns the code in SAE J2012 notation, ie P0105
+func (d DTC) String() string {
+	return fmt.Sprintf("%c%d%03X", "PCBU"[d.Code>>14], (d.Code>>12)&0x03, d.Code&0x0FFF)
+}
+
+// Description returns the text description of the code if known
+func (d DTC) Description() string {
+	if desc, ok := dtcDescriptions[d.String()]; ok {
+		return desc
+	}
+	return "Unknown trouble code"
+}
+
+// Active reports if the fault is present at the time of the request
+func (d DTC) Active() bool {
+	return d.Status&0x60 == 0x60
+}
+
+// MIL reports if the fault turns on the check engine lamp
+func (d DTC) MIL() bool {
+	return d.Status&0x80 == 0x80
+}
+
+func (d DTC) StatusString() string {
+	var status string
+	switch d.Status & 0x60 {
+	case 0x00:
+		status = "No fault"
+	case 0x20:
+		status = "Not present"
+	case 0x40:
+		status = "Maturing"
+	case 0x60:
+		status = "Present"
+	}
+	if d.MIL() {
+		status += ", MIL on"
+	}
+	return status
+}
+
+// DecodeDTCs decodes the response data of ReadDiagnosticTroubleCodesByStatus,
+// a count followed by two bytes of code and one byte of status for each DTC
+func DecodeDTCs(data []byte) ([]DTC, error) {
+	if len(data) < 1 {
+		return nil, fmt.Errorf("DecodeDTCs: empty response")
+	}
+	count := int(data[0])
+	if len(data)-1 < count*3 {
+		return nil, fmt.Errorf("DecodeDTCs: expected %d codes, got %d bytes", count, len(data)-1)
+	}
+	dtcs := make([]DTC, count)
+	for i := range dtcs {
+		pos := 1 + i*3
+		dtcs[i] = DTC{
+			Code:   uint16(data[pos])<<8 | uint16(data[pos+1]),
+			Status: data[pos+2],
+		}
+	}
+	return dtcs, nil
+}
+
+// ReadDiagnosticTroubleCodesByStatus returns all stored trouble codes
+func (t *Client) ReadDiagnosticTroubleCodesByStatus(ctx context.Context) ([]DTC, error) {
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: READ_DIAGNOSTIC_TROUBLE_CODES_BY_STATUS, Data: []byte{0x02, 0xFF, 0x00}})
+	if err != nil {
+		return nil, fmt.Errorf("ReadDiagnosticTroubleCodesByStatus: %w", err)
+	}
+	return DecodeDTCs(reply.Data)
+}
+
+// ClearDiagnosticInformation clears all stored trouble codes
+func (t *Client) ClearDiagnosticInformation(ctx context.Context) error {
+	if _, err := t.SendRequest(ctx, &KWPRequest{ServiceID: CLEAR_DIAGNOSTIC_INFORMATION, Data: []byte{0xFF, 0x00}}); err != nil {
+		return fmt.Errorf("ClearDiagnosticInformation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package kwp2000
+
+// Generic SAE J2012 descriptions of powertrain codes, manufacturer specific codes are not included
+// and not every code listed is set by Trionic 7
+var dtcDescriptions = map[string]string{
+	"P0100": "Mass or Volume Air Flow Circuit Malfunction",
+	"P0101": "Mass or Volume Air Flow Circuit Range/Performance Problem",
+	"P0102": "Mass or Volume Air Flow Circuit Low Input",
+	"P0103": "Mass or Volume Air Flow Circuit High Input",
+	"P0105": "Manifold Absolute Pressure/Barometric Pressure Circuit Malfunction",
+	"P0106": "Manifold Absolute Pressure/Barometric Pressure Circuit Range/Performance Problem",
+	"P0107": "Manifold Absolute Pressure/Barometric Pressure Circuit Low Input",
+	"P0108": "Manifold Absolute Pressure/Barometric Pressure Circuit High Input",
+	"P0110": "Intake Air Temperature Circuit Malfunction",
+	"P0112": "Intake Air Temperature Circuit Low Input",
+	"P0113": "Intake Air Temperature Circuit High Input",
+	"P0115": "Engine Coolant Temperature Circuit Malfunction",
+	"P0116": "Engine Coolant Temperature Circuit Range/Performance Problem",
+	"P0117": "Engine Coolant Temperature Circuit Low Input",
+	"P0118": "Engine Coolant Temperature Circuit High Input",
+	"P0120": "Throttle Position Sensor A Circuit Malfunction",
+	"P0121": "Throttle Position Sensor A Circuit Range/Performance Problem",
+	"P0122": "Throttle Position Sensor A Circuit Low Input",
+	"P0123": "Throttle Position Sensor A Circuit High Input",
+	"P0125": "Insufficient Coolant Temperature for Closed Loop Fuel Control",
+	"P0130": "O2 Sensor Circuit Malfunction (Bank 1 Sensor 1)",
+	"P0131": "O2 Sensor Circuit Low Voltage (Bank 1 Sensor 1)",
+	"P0132": "O2 Sensor Circuit High Voltage (Bank 1 Sensor 1)",
+	"P0133": "O2 Sensor Circuit Slow Response (Bank 1 Sensor 1)",
+	"P0134": "O2 Sensor Circuit No Activity Detected (Bank 1 Sensor 1)",
+	"P0135": "O2 Sensor Heater Circuit Malfunction (Bank 1 Sensor 1)",
+	"P0136": "O2 Sensor Circuit Malfunction (Bank 1 Sensor 2)",
+	"P0137": "O2 Sensor Circuit Low Voltage (Bank 1 Sensor 2)",
+	"P0138": "O2 Sensor Circuit High Voltage (Bank 1 Sensor 2)",
+	"P0140": "O2 Sensor Circuit No Activity Detected (Bank 1 Sensor 2)",
+	"P0141": "O2 Sensor Heater Circuit Malfunction (Bank 1 Sensor 2)",
+	"P0170": "Fuel Trim Malfunction (Bank 1)",
+	"P0171": "System too Lean (Bank 1)",
+	"P0172": "System too Rich (Bank 1)",
+	"P0201": "Injector Circuit Malfunction - Cylinder 1",
+	"P0202": "Injector Circuit Malfunction - Cylinder 2",
+	"P0203": "Injector Circuit Malfunction - Cylinder 3",
+	"P0204": "Injector Circuit Malfunction - Cylinder 4",
+	"P0205": "Injector Circuit Malfunction - Cylinder 5",
+	"P0206": "Injector Circuit Malfunction - Cylinder 6",
+	"P0230": "Fuel Pump Primary Circuit Malfunction",
+	"P0234": "Engine Overboost Condition",
+	"P0243": "Turbocharger Wastegate Solenoid A Malfunction",
+	"P0245": "Turbocharger Wastegate Solenoid A Low",
+	"P0246": "Turbocharger Wastegate Solenoid A High",
+	"P0300": "Random/Multiple Cylinder Misfire Detected",
+	"P0301": "Cylinder 1 Misfire Detected",
+	"P0302": "Cylinder 2 Misfire Detected",
+	"P0303": "Cylinder 3 Misfire Detected",
+	"P0304": "Cylinder 4 Misfire Detected",
+	"P0305": "Cylinder 5 Misfire Detected",
+	"P0306": "Cylinder 6 Misfire Detected",
+	"P0325": "Knock Sensor 1 Circuit Malfunction",
+	"P0335": "Crankshaft Position Sensor A Circuit Malfunction",
+	"P0336": "Crankshaft Position Sensor A Circuit Range/Performance",
+	"P0340": "Camshaft Position Sensor Circuit Malfunction",
+	"P0420": "Catalyst System Efficiency Below Threshold (Bank 1)",
+	"P0440": "Evaporative Emission Control System Malfunction",
+	"P0442": "Evaporative Emission Control System Leak Detected (small leak)",
+	"P0443": "Evaporative Emission Control System Purge Control Valve Circuit Malfunction",
+	"P0444": "Evaporative Emission Control System Purge Control Valve Circuit Open",
+	"P0445": "Evaporative Emission Control System Purge Control Valve Circuit Shorted",
+	"P0455": "Evaporative Emission Control System Leak Detected (gross leak)",
+	"P0480": "Cooling Fan 1 Control Circuit Malfunction",
+	"P0481": "Cooling Fan 2 Control Circuit Malfunction",
+	"P0500": "Vehicle Speed Sensor Malfunction",
+	"P0501": "Vehicle Speed Sensor Range/Performance",
+	"P0505": "Idle Control System Malfunction",
+	"P0506": "Idle Control System RPM Lower Than Expected",
+	"P0507": "Idle Control System RPM Higher Than Expected",
+	"P0530": "A/C Refrigerant Pressure Sensor Circuit Malfunction",
+	"P0560": "System Voltage Malfunction",
+	"P0562": "System Voltage Low",
+	"P0563": "System Voltage High",
+	"P0600": "Serial Communication Link Malfunction",
+	"P0601": "Internal Control Module Memory Check Sum Error",
+	"P0604": "Internal Control Module Random Access Memory (RAM) Error",
+	"P0605": "Internal Control Module Read Only Memory (ROM) Error",
+	"P0606": "ECM/PCM Processor Fault",
+	"P0650": "Malfunction Indicator Lamp (MIL) Control Circuit Malfunction",
+	"P0703": "Brake Switch B Circuit Malfunction",
+	"P0704": "Clutch Switch Input Circuit Malfunction",
+}
@@ -36,53 +36,86 @@ const (
 	SERVICE_NOT_SUPPORTED_IN_ACTIVE_DIAGNOSTIC_SESSION = 0x80
 )
 
+// Sentinel errors for the negative response codes, a NegativeResponseError unwraps to one of these
+var (
+	ErrGeneralReject                         = errors.New("general reject")
+	ErrServiceNotSupported                   = errors.New("mode not supported")
+	ErrSubFunctionNotSupported               = errors.New("sub-function not supported - invalid format")
+	ErrBusyRepeatRequest                     = errors.New("busy, repeat request")
+	ErrConditionsNotCorrect                  = errors.New("conditions not correct or request sequence error")
+	ErrRoutineNotComplete                    = errors.New("routine not completed or service in progress")
+	ErrRequestOutOfRange                     = errors.New("request out of range or session dropped")
+	ErrSecurityAccessDenied                  = errors.New("security access denied")
+	ErrInvalidKey                            = errors.New("invalid key supplied")
+	ErrExceedNumberOfAttempts                = errors.New("exceeded number of attempts to get security access")
+	ErrRequiredTimeDelayNotExpired           = errors.New("required time delay not expired, you cannot gain security access at this moment")
+	ErrDownloadNotAccepted                   = errors.New("download (PC -> ECU) not accepted")
+	ErrImproperDownloadType                  = errors.New("improper download (PC -> ECU) type")
+	ErrCannotDownloadToAddress               = errors.New("unable to download (PC -> ECU) to specified address")
+	ErrCannotDownloadNumberOfBytes           = errors.New("unable to download (PC -> ECU) number of bytes requested")
+	ErrUploadNotAccepted                     = errors.New("upload (ECU -> PC) not accepted")
+	ErrImproperUploadType                    = errors.New("improper upload (ECU -> PC) type")
+	ErrCannotUploadFromAddress               = errors.New("unable to upload (ECU -> PC) for specified address")
+	ErrCannotUploadNumberOfBytes             = errors.New("unable to upload (ECU -> PC) number of bytes requested")
+	ErrTransferSuspended                     = errors.New("transfer suspended")
+	ErrTransferAborted                       = errors.New("transfer aborted")
+	ErrIllegalAddressInBlockTransfer         = errors.New("illegal address in block transfer")
+	ErrIllegalByteCountInBlockTransfer       = errors.New("illegal byte count in block transfer")
+	ErrIllegalBlockTransferType              = errors.New("illegal block transfer type")
+	ErrBlockTransferChecksum                 = errors.New("block transfer data checksum error")
+	ErrResponsePending                       = errors.New("response pending")
+	ErrIncorrectByteCountDuringBlockTransfer = errors.New("incorrect byte count during block transfer")
+	ErrServiceNotSupportedInSession          = errors.New("service not supported in current diagnostics session")
+)
+
+// TranslateErrorCode returns the sentinel error for a negative response code, nil for 0x00
 func TranslateErrorCode(p byte) error {
 	switch p {
 	case 0x00:
 		//return "Affirmative response"
 		return nil
 	case GENERAL_REJECT:
-		return errors.New("general reject")
+		return ErrGeneralReject
 	case SERVICE_NOT_SUPPORTED:
-		return errors.New("mode not supported")
+		return ErrServiceNotSupported
 	case SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT:
-		return errors.New("sub-function not supported - invalid format")
+		return ErrSubFunctionNotSupported
 	case BUSY_REPEAT_REQUEST:
-		return errors.New("busy, repeat request")
+		return ErrBusyRepeatRequest
 	case CONDITIONS_NOT_CORRECT_OR_REQUEST_SEQUENCE_ERROR:
-		return errors.New("conditions not correct or request sequence error")
+		return ErrConditionsNotCorrect
 	case ROUTINE_NOT_COMPLETE_OR_SERVICE_IN_PROGRESS:
-		return errors.New("routine not completed or service in progress")
+		return ErrRoutineNotComplete
 	case REQUEST_OUT_OF_RANGE:
-		return errors.New("request out of range or session dropped")
+		return ErrRequestOutOfRange
 	case SECURITY_ACCESS_DENIED_OR_REQUESTED:
-		return errors.New("security access denied")
+		return ErrSecurityAccessDenied
 	case 0x34:
 		return errors.New("security access allowed")
 	case INVALID_KEY:
-		return errors.New("invalid key supplied")
+		return ErrInvalidKey
 	case EXCEED_NUMBER_OF_ATTEMPTS:
-		return errors.New("exceeded number of attempts to get security access")
+		return ErrExceedNumberOfAttempts
 	case REQUIRED_TIME_DELAY_NOT_EXPIRED:
-		return errors.New("required time delay not expired, you cannot gain security access at this moment")
+		return ErrRequiredTimeDelayNotExpired
 	case DOWNLOAD_NOT_ACCEPTED:
-		return errors.New("download (PC -> ECU) not accepted")
+		return ErrDownloadNotAccepted
 	case IMPROPER_DOWNLOAD_TYPE:
-		return errors.New("improper download (PC -> ECU) type")
+		return ErrImproperDownloadType
 	case CANNOT_DOWNLOAD_TO_SPECIFIED_ADDRESS:
-		return errors.New("unable to download (PC -> ECU) to specified address")
+		return ErrCannotDownloadToAddress
 	case CANNOT_DOWNLOAD_NUMBER_OF_BYTES_REQUESTED:
-		return errors.New("unable to download (PC -> ECU) number of bytes requested")
+		return ErrCannotDownloadNumberOfBytes
 	case 0x44:
 		return errors.New("ready for download")
 	case UPLOAD_NOT_ACCEPTED:
-		return errors.New("upload (ECU -> PC) not accepted")
+		return ErrUploadNotAccepted
 	case IMPROPER_UPLOAD_TYPE:
-		return errors.New("improper upload (ECU -> PC) type")
+		return ErrImproperUploadType
 	case CANNOT_UPLOAD_FROM_SPECIFIED_ADDRESS:
-		return errors.New("unable to upload (ECU -> PC) for specified address")
+		return ErrCannotUploadFromAddress
 	case CANNOT_UPLOAD_NUMBER_OF_BYTES_REQUESTED:
-		return errors.New("unable to upload (ECU -> PC) number of bytes requested")
+		return ErrCannotUploadNumberOfBytes
 	case 0x54:
 		return errors.New("ready for upload")
 	case 0x61:
@@ -94,24 +127,58 @@ func TranslateErrorCode(p byte) error {
 	case 0x64:
 		return errors.New("abnormal exit without results")
 	case TRANSFER_SUSPENDED:
-		return errors.New("transfer suspended")
+		return ErrTransferSuspended
 	case TRANSFER_ABORTED:
-		return errors.New("transfer aborted")
+		return ErrTransferAborted
 	case ILLEGAL_ADDRESS_IN_BLOCK_TRANSFER:
-		return errors.New("illegal address in block transfer")
+		return ErrIllegalAddressInBlockTransfer
 	case ILLEGAL_BYTE_COUNT_IN_BLOCK_TRANSFER:
-		return errors.New("illegal byte count in block transfer")
+		return ErrIllegalByteCountInBlockTransfer
 	case ILLEGAL_BLOCK_TRANSFER_TYPE:
-		return errors.New("illegal block transfer type")
+		return ErrIllegalBlockTransferType
 	case BLOCK_TRANSFER_DATA_CHECKSUM_ERROR:
-		return errors.New("block transfer data checksum error")
+		return ErrBlockTransferChecksum
 	case REQUEST_CORRECTLY_RECEIVED_RESPONSE_PENDING:
-		return errors.New("response pending")
+		return ErrResponsePending
 	case INCORRECT_BYTE_COUNT_DURING_BLOCK_TRANSFER:
-		return errors.New("incorrect byte count during block transfer")
+		return ErrIncorrectByteCountDuringBlockTransfer
 	case SERVICE_NOT_SUPPORTED_IN_ACTIVE_DIAGNOSTIC_SESSION:
-		return errors.New("service not supported in current diagnostics session")
+		return ErrServiceNotSupportedInSession
 	default:
 		return fmt.Errorf("unknown error %X", p)
 	}
 }
+
+// NegativeResponseError is a negative response (7F) from the ECU to a service request
+type NegativeResponseError struct {
+	ServiceID byte
+	Code      byte
+	// Frame is the raw CAN frame data the negative response was received in
+	Frame []byte
+}
+
+func newNegativeResponseError(d []byte) *NegativeResponseError {
+	e := &NegativeResponseError{Frame: append([]byte(nil), d...)}
+	if len(d) > 5 {
+		e.ServiceID, e.Code = d[4], d[5]
+	}
+	return e
+}
+
+func (e *NegativeResponseError) Error() string {
+	return fmt.Sprintf("service %02X negative response %02X: %v", e.ServiceID, e.Code, TranslateErrorCode(e.Code))
+}
+
+// Unwrap returns the sentinel error for the response code so callers can use errors.Is
+func (e *NegativeResponseError) Unwrap() error {
+	return TranslateErrorCode(e.Code)
+}
+
+// Temporary reports whether the same request may succeed if it is repeated later
+func (e *NegativeResponseError) Temporary() bool {
+	switch e.Code {
+	case BUSY_REPEAT_REQUEST, ROUTINE_NOT_COMPLETE_OR_SERVICE_IN_PROGRESS, REQUIRED_TIME_DELAY_NOT_EXPIRED, REQUEST_CORRECTLY_RECEIVED_RESPONSE_PENDING:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package kwp2000
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidFrame is returned when a response frame is too short or arrives out of sequence
+var ErrInvalidFrame = errors.New("invalid frame")
+
+// checkFrame makes sure a response frame holds a full 8 bytes of data
+func checkFrame(d []byte) error {
+	if len(d) != 8 {
+		return fmt.Errorf("%w: expected 8 bytes, got %d: %X", ErrInvalidFrame, len(d), d)
+	}
+	return nil
+}
+
+// checkNextFrame validates a consecutive frame of a multi-frame response, byte 0 holds the
+// number of frames left which must count down by one from the previous frame
+func checkNextFrame(prev byte, d []byte) error {
+	if err := checkFrame(d); err != nil {
+		return err
+	}
+	if d[0]&0x40 != 0 || int(d[0]&0x3F) != int(prev&0x3F)-1 {
+		return fmt.Errorf("%w: frame %02X out of sequence after %02X", ErrInvalidFrame, d[0], prev)
+	}
+	return nil
+}
+
+// lastFrame reports whether no frames are left after d
+func lastFrame(d []byte) bool {
+	return d[0]&0x3F == 0
+}
@@ -0,0 +1,124 @@
+package kwp2000
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// FreezeFrameSymbols is the expected layout of a Trionic 7 freeze frame, the values are sent in this order.
+// There is no public documentation of the layout, this is an unverified guess at the operating conditions
+// a frame holds, replace it with the layout of the ECU at hand if the decoded values look wrong
+var FreezeFrameSymbols = []string{
+	"ActualIn.n_Engine",
+	"ActualIn.T_Engine",
+	"ActualIn.T_AirInlet",
+	"ActualIn.p_AirInlet",
+	"ActualIn.U_Batt",
+	"Out.X_AccPedal",
+	"In.v_Vehicle",
+	"MAF.m_AirInlet",
+}
+
+// FreezeFrame is the snapshot of operating conditions the ECU stored when a DTC was set
+type FreezeFrame struct {
+	Number byte
+	DTC    DTC
+	Data   []byte
+}
+
+// Decode returns the values of the freeze frame in the order of FreezeFrameSymbols, symbols holds the loaded
+// symbol metadata used for the size and scaling of each value. The value of a symbol without metadata is nil
+// and so is every value after it as their position is unknown, the names of those are returned as unknown
+func (f *FreezeFrame) Decode(symbols map[string]*VarDefinition) (values []*VarDefinition, unknown []string) {
+	r := bytes.NewReader(f.Data)
+	values = make([]*VarDefinition, len(FreezeFrameSymbols))
+	for i, name := range FreezeFrameSymbols {
+		sym, ok := symbols[name]
+		if !ok {
+			return values, FreezeFrameSymbols[i:]
+		}
+		v := &VarDefinition{
+			Name:             sym.Name,
+			Method:           sym.Method,
+			Value:            sym.Value,
+			Type:             sym.Type,
+			Length:           sym.Length,
+			Unit:             sym.Unit,
+			Correctionfactor: sym.Correctionfactor,
+		}
+		if err := v.Read(r); err != nil {
+			// the frame is shorter than the layout
+			return values, FreezeFrameSymbols[i:]
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// ReadFreezeFrameData reads freeze frame number
+func (t *Client) ReadFreezeFrameData(ctx context.Context, number byte) (*FreezeFrame, error) {
+	// recordAccessMethodIdentifier 0x00, requestAllData
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: READ_FREEZEFRAME_DATA, Data: []byte{number, 0x00}})
+	if err != nil {
+		return nil, fmt.Errorf("ReadFreezeFrameData: %w", err)
+	}
+	if len(reply.Data) < 3 {
+		return nil, fmt.Errorf("ReadFreezeFrameData: short response %X", reply.Data)
+	}
+	if reply.Data[0] != number {
+		return nil, fmt.Errorf("ReadFreezeFrameData: expected frame %d, got %d", number, reply.Data[0])
+	}
+	return &FreezeFrame{
+		Number: number,
+		DTC:    DTC{Code: uint16(reply.Data[1])<<8 | uint16(reply.Data[2])},
+		Data:   reply.Data[3:],
+	}, nil
+}
+
+// ReadFreezeFrames reads the freeze frame of each DTC, the ECU numbers the frames in the
+// order the DTCs are reported by ReadDiagnosticTroubleCodesByStatus
+func (t *Client) ReadFreezeFrames(ctx context.Context, dtcs []DTC) ([]*FreezeFrame, error) {
+	frames := make([]*FreezeFrame, 0, len(dtcs))
+	for i, dtc := range dtcs {
+		frame, err := t.ReadFreezeFrameData(ctx, byte(i))
+		if err != nil {
+			return nil, err
+		}
+		if frame.DTC.Code != dtc.Code {
+			return nil, fmt.Errorf("ReadFreezeFrames: frame %d belongs to %s, expected %s", i, frame.DTC, dtc)
+		}
+		frame.DTC = dtc
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// WriteFreezeFrames writes the decoded freeze frames as CSV, one row per DTC. Values that can not be
+// decoded are written as unknown, the raw frame data is kept in the last column
+func WriteFreezeFrames(w io.Writer, frames []*FreezeFrame, symbols map[string]*VarDefinition) error {
+	cw := csv.NewWriter(w)
+	header := append([]string{"DTC", "Status", "Description"}, FreezeFrameSymbols...)
+	if err := cw.Write(append(header, "Data")); err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		values, _ := frame.Decode(symbols)
+		row := []string{frame.DTC.String(), frame.DTC.StatusString(), frame.DTC.Description()}
+		for _, v := range values {
+			if v == nil {
+				row = append(row, "unknown")
+				continue
+			}
+			row = append(row, v.StringValue())
+		}
+		row = append(row, fmt.Sprintf("%X", frame.Data))
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,271 @@
+package kwp2000
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roffe/gocan"
+)
+
+// maxScriptFrames bounds the frames of a script and with it the time of a fuzz run
+const maxScriptFrames = 32
+
+// scriptedAdapter stands in for an ECU sending arbitrary data, every frame the tester sends
+// is answered with the next frame of the script until it runs out
+type scriptedAdapter struct {
+	send, recv chan gocan.CANFrame
+	frames     []gocan.CANFrame
+	close      chan struct{}
+	closeOnce  sync.Once
+}
+
+// newScriptedAdapter parses a script of frames, each is a header byte followed by the data.
+// Bit 0 of the header selects the response or the chunk confirmation id and the rest the data length,
+// lengths above 8 are kept so short and long frames reach the client too
+func newScriptedAdapter(script []byte) *scriptedAdapter {
+	a := &scriptedAdapter{
+		// every send is buffered so the client never waits on the adapter after the script ended
+		send:  make(chan gocan.CANFrame, 4*maxScriptFrames),
+		recv:  make(chan gocan.CANFrame, 10),
+		close: make(chan struct{}),
+	}
+	for len(script) > 0 && len(a.frames) < maxScriptFrames {
+		h := script[0]
+		script = script[1:]
+		id := uint32(0x258)
+		if h&1 == 1 {
+			id = REQ_CHUNK_CONF_ID
+		}
+		n := int(h>>1) % 10
+		if n > len(script) {
+			n = len(script)
+		}
+		a.frames = append(a.frames, gocan.NewFrame(id, append([]byte(nil), script[:n]...), gocan.Incoming))
+		script = script[n:]
+	}
+	return a
+}
+
+func (a *scriptedAdapter) Init(ctx context.Context) error {
+	go a.run(ctx)
+	return nil
+}
+
+func (a *scriptedAdapter) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.close:
+			return
+		case <-a.send:
+			if len(a.frames) == 0 {
+				continue
+			}
+			f := a.frames[0]
+			a.frames = a.frames[1:]
+			// give the client time to subscribe before the answer arrives
+			time.Sleep(100 * time.Microsecond)
+			select {
+			case a.recv <- f:
+			case <-ctx.Done():
+				return
+			case <-a.close:
+				return
+			}
+		}
+	}
+}
+
+func (a *scriptedAdapter) Name() string                { return "scripted" }
+func (a *scriptedAdapter) SetFilter([]uint32) error    { return nil }
+func (a *scriptedAdapter) Recv() <-chan gocan.CANFrame { return a.recv }
+func (a *scriptedAdapter) Send() chan<- gocan.CANFrame { return a.send }
+func (a *scriptedAdapter) Close() error {
+	a.closeOnce.Do(func() { close(a.close) })
+	return nil
+}
+
+// fuzzClient returns a client talking to a scripted adapter with timeouts short enough for fuzzing,
+// the context ends every exchange within 20 ms
+func fuzzClient(t *testing.T, script []byte) (context.Context, *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	t.Cleanup(cancel)
+	cl, err := gocan.New(ctx, newScriptedAdapter(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cl.Close() })
+	return ctx, New(cl,
+		WithTimeout(time.Millisecond),
+		WithReadDataTimeout(time.Millisecond),
+		WithChunkTimeout(time.Millisecond),
+		WithReadMemoryTimeout(time.Millisecond, time.Millisecond),
+		WithResponsePendingTimeout(time.Millisecond),
+	)
+}
+
+// script builds a fuzz script from full response frames
+func script(frames ...[]byte) []byte {
+	var out []byte
+	for _, f := range frames {
+		out = append(out, byte(len(f)<<1))
+		out = append(out, f...)
+	}
+	return out
+}
+
+func FuzzCheckFrame(f *testing.F) {
+	f.Add(byte(0xC1), []byte{0x00, 0xBF, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	f.Add(byte(0x42), []byte{0x01})
+	f.Fuzz(func(t *testing.T, prev byte, d []byte) {
+		if err := checkFrame(d); err == nil {
+			lastFrame(d)
+		}
+		if err := checkNextFrame(prev, d); err == nil {
+			lastFrame(d)
+		}
+	})
+}
+
+func FuzzNegativeResponse(f *testing.F) {
+	f.Add([]byte{0xC0, 0xBF, 0x03, 0x7F, 0x21, 0x78, 0x00, 0x00})
+	f.Add([]byte{0x7F})
+	f.Fuzz(func(t *testing.T, d []byte) {
+		e := newNegativeResponseError(d)
+		_ = e.Error()
+		e.Temporary()
+		errors.Is(e, ErrBusyRepeatRequest)
+	})
+}
+
+func FuzzDecodeDTCs(f *testing.F) {
+	f.Add([]byte{0x02, 0x01, 0x07, 0x2F, 0x43, 0x00, 0x08})
+	f.Add([]byte{0x05, 0x01})
+	f.Fuzz(func(t *testing.T, d []byte) {
+		dtcs, err := DecodeDTCs(d)
+		if err != nil {
+			return
+		}
+		if len(dtcs) != int(d[0]) {
+			t.Fatalf("count %d, decoded %d", d[0], len(dtcs))
+		}
+		for _, dtc := range dtcs {
+			_ = dtc.String() + dtc.Description() + dtc.StatusString()
+		}
+	})
+}
+
+func FuzzIdentString(f *testing.F) {
+	f.Add([]byte("EU0AF01C.55P\x00\x00  "))
+	f.Fuzz(func(t *testing.T, d []byte) {
+		identString(d)
+	})
+}
+
+// fuzzVar returns a variable of the given type and length, lengths are kept within one local id response
+func fuzzVar(name string, typ byte, length uint8, mask uint16) *VarDefinition {
+	return &VarDefinition{Name: name, Type: typ, Length: uint16(length%8) + 1, Mask: mask, Correctionfactor: "0.1"}
+}
+
+func FuzzLocalIDRead(f *testing.F) {
+	f.Add([]byte{0x01, 0x02, 0x03}, byte(0), uint8(1), byte(SIGNED), uint8(0), uint16(0))
+	f.Add([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, byte(BITFIELD), uint8(3), byte(CHAR), uint8(1), uint16(0x0F))
+	f.Fuzz(func(t *testing.T, d []byte, typ1 byte, len1 uint8, typ2 byte, len2 uint8, mask uint16) {
+		g := &LocalIDGroup{ID: 0xF0, Variables: []*VarDefinition{
+			fuzzVar("a", typ1, len1, mask),
+			fuzzVar("b", typ2, len2, 0),
+		}}
+		if err := g.Read(d); err != nil {
+			return
+		}
+		for _, v := range g.Variables {
+			v.StringValue()
+			v.Values()
+			v.Channels()
+			v.T7L()
+			v.Tuple()
+		}
+	})
+}
+
+func FuzzFreezeFrameDecode(f *testing.F) {
+	f.Add([]byte{0x0B, 0xB8, 0x5A, 0x28, 0x03, 0xE8, 0x00, 0x8C, 0x14, 0x32, 0x01, 0x2C})
+	f.Fuzz(func(t *testing.T, d []byte) {
+		symbols := make(map[string]*VarDefinition)
+		for i, name := range FreezeFrameSymbols {
+			symbols[name] = &VarDefinition{Name: name, Type: byte(i % 2), Length: uint16(1 + i%2), Correctionfactor: "0.1"}
+		}
+		frame := &FreezeFrame{DTC: DTC{Code: 0x0107}, Data: d}
+		values, unknown := frame.Decode(symbols)
+		if len(values) != len(FreezeFrameSymbols) {
+			t.Fatalf("decoded %d values", len(values))
+		}
+		for _, v := range values {
+			if v != nil {
+				v.StringValue()
+			}
+		}
+		_ = unknown
+		if err := WriteFreezeFrames(io.Discard, []*FreezeFrame{frame}, symbols); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// FuzzClient runs the frame handling of the client against a scripted ECU, op selects the exchange:
+// a request, a data transfer, a memory read or a flash transfer
+func FuzzClient(f *testing.F) {
+	// single frame positive response
+	f.Add(script([]byte{0xC0, 0xBF, 0x02, 0x61, 0xF0, 0x00, 0x00, 0x00}), byte(0), uint8(1))
+	// two frame response
+	f.Add(script(
+		[]byte{0xC1, 0xBF, 0x08, 0x5A, 0x90, 0x59, 0x53, 0x33},
+		[]byte{0x00, 0xBF, 0x46, 0x48, 0x34, 0x31, 0x00, 0x00},
+	), byte(0), uint8(1))
+	// response pending then negative response
+	f.Add(script(
+		[]byte{0xC0, 0xBF, 0x03, 0x7F, 0x21, 0x78, 0x00, 0x00},
+		[]byte{0xC0, 0xBF, 0x03, 0x7F, 0x21, 0x31, 0x00, 0x00},
+	), byte(0), uint8(1))
+	// multi-frame request acked on the chunk confirmation id
+	f.Add(append([]byte{8<<1 | 1, 0x00, 0xA1, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, script([]byte{0xC0, 0xBF, 0x02, 0x61, 0xF0, 0x00, 0x00, 0x00})...), byte(0), uint8(6))
+	f.Add(script(
+		[]byte{0xC1, 0xBF, 0x06, 0x61, 0xF0, 0x01, 0x02, 0x03},
+		[]byte{0x00, 0xBF, 0x04, 0x05, 0x06, 0x00, 0x00, 0x00},
+	), byte(1), uint8(6))
+	f.Add(script([]byte{0x00, 0xBF, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}), byte(1), uint8(4))
+	// the first frame of the jump is not answered, the second is
+	f.Add(script(
+		[]byte{},
+		[]byte{0xC0, 0xBF, 0x02, 0x6C, 0xF0, 0x00, 0x00, 0x00},
+		[]byte{0xC0, 0xBF, 0x06, 0x61, 0xF0, 0x01, 0x02, 0x03},
+	), byte(2), uint8(3))
+	f.Add(script(
+		[]byte{0xC1, 0xBF, 0x0B, 0x76, 0x31, 0x00, 0x00, 0x00},
+		[]byte{0x00, 0xBF, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		[]byte{0xC0, 0xBF, 0x02, 0x76, 0x31, 0x00, 0x00, 0x00},
+	), byte(3), uint8(0))
+	f.Fuzz(func(t *testing.T, script []byte, op byte, n uint8) {
+		ctx, k := fuzzClient(t, script)
+		switch op % 4 {
+		case 0:
+			data := make([]byte, n)
+			k.SendRequest(ctx, &KWPRequest{ServiceID: READ_DATA_BY_LOCAL_IDENTIFIER, Data: data})
+		case 1:
+			if b, err := k.recvData(ctx, int(n)); err == nil && len(b) > int(n) {
+				t.Fatalf("asked for %d bytes, got %d", n, len(b))
+			}
+		case 2:
+			if b, err := k.ReadMemoryByAddress(ctx, 0x1000, int(n)); err == nil && len(b) > int(n) {
+				t.Fatalf("asked for %d bytes, got %d", n, len(b))
+			}
+		case 3:
+			k.TransferData(ctx)
+		}
+	})
+}
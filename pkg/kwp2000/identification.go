@@ -0,0 +1,94 @@
+package kwp2000
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ECU identification options as used by Trionic 7
+const (
+	ID_VIN                  = 0x90
+	ID_HARDWARE_NUMBER      = 0x91
+	ID_IMMOBILIZER_CODE     = 0x92
+	ID_SOFTWARE_PART_NUMBER = 0x94
+	ID_SOFTWARE_VERSION     = 0x95
+	ID_ENGINE_TYPE          = 0x97
+)
+
+// ECUIdentification ties a log to the car and calibration it came from
+type ECUIdentification struct {
+	VIN                string `json:"vin"`
+	SoftwareVersion    string `json:"software_version"`
+	SoftwarePartNumber string `json:"software_part_number"`
+	HardwareNumber     string `json:"hardware_number"`
+	EngineType         string `json:"engine_type"`
+	ImmobilizerCode    string `json:"immobilizer_code"`
+}
+
+// Fields returns the identification as key/value pairs in a fixed order
+func (e *ECUIdentification) Fields() [][2]string {
+	return [][2]string{
+		{"VIN", e.VIN},
+		{"SoftwareVersion", e.SoftwareVersion},
+		{"SoftwarePartNumber", e.SoftwarePartNumber},
+		{"HardwareNumber", e.HardwareNumber},
+		{"EngineType", e.EngineType},
+		{"ImmobilizerCode", e.ImmobilizerCode},
+	}
+}
+
+func (e *ECUIdentification) String() string {
+	return fmt.Sprintf("VIN: %s, SW: %s (%s), HW: %s, Engine: %s, Immo: %s",
+		e.VIN, e.SoftwareVersion, e.SoftwarePartNumber, e.HardwareNumber, e.EngineType, e.ImmobilizerCode)
+}
+
+// ReadECUIdentificationOption returns the raw value of a single identification option
+func (t *Client) ReadECUIdentificationOption(ctx context.Context, id byte) ([]byte, error) {
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: READ_ECU_IDENTIFICATION, Data: []byte{id}})
+	if err != nil {
+		return nil, fmt.Errorf("ReadECUIdentification: %w", err)
+	}
+	if len(reply.Data) < 1 || reply.Data[0] != id {
+		return nil, fmt.Errorf("ReadECUIdentification: invalid response for option 0x%02X: %X", id, reply.Data)
+	}
+	return reply.Data[1:], nil
+}
+
+// ReadECUIdentification reads VIN, software, hardware, engine type and immobilizer code from the ECU.
+// Options the ECU fails to report are left empty and returned joined as the error, the identification
+// is nil only if no option could be read
+func (t *Client) ReadECUIdentification(ctx context.Context) (*ECUIdentification, error) {
+	ident := &ECUIdentification{}
+	var errs []error
+	for _, opt := range []struct {
+		id  byte
+		dst *string
+	}{
+		{ID_VIN, &ident.VIN},
+		{ID_SOFTWARE_VERSION, &ident.SoftwareVersion},
+		{ID_SOFTWARE_PART_NUMBER, &ident.SoftwarePartNumber},
+		{ID_HARDWARE_NUMBER, &ident.HardwareNumber},
+		{ID_ENGINE_TYPE, &ident.EngineType},
+		{ID_IMMOBILIZER_CODE, &ident.ImmobilizerCode},
+	} {
+		data, err := t.ReadECUIdentificationOption(ctx, opt.id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		*opt.dst = identString(data)
+	}
+	if len(errs) == 6 {
+		return nil, errors.Join(errs...)
+	}
+	t.softwareVersion = ident.SoftwareVersion
+	return ident, errors.Join(errs...)
+}
+
+// identString trims padding, the ECU pads with both spaces and null bytes
+func identString(data []byte) string {
+	return strings.TrimSpace(string(bytes.Trim(data, "\x00\xFF")))
+}
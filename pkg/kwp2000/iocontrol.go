@@ -0,0 +1,57 @@
+package kwp2000
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// inputOutputControlParameter
+const (
+	IO_RETURN_CONTROL_TO_ECU = 0x00
+	IO_REPORT_CURRENT_STATE  = 0x01
+	IO_SHORT_TERM_ADJUSTMENT = 0x07
+)
+
+// MaxActuatorDuration is the longest an output may be controlled by the tester
+const MaxActuatorDuration = 30 * time.Second
+
+// Actuator is an output of the ECU that can be controlled by InputOutputControlByLocalIdentifier.
+// The local ids of the Trionic 7 outputs are not documented, the id has to be known by the user
+type Actuator struct {
+	Name string
+	ID   byte
+	// PWM outputs take a duty cycle in percent, others are switched on with 0x01
+	PWM bool
+}
+
+// InputOutputControlByLocalIdentifier sends a control parameter with optional state to an output
+// and returns the control state reported back by the ECU
+func (t *Client) InputOutputControlByLocalIdentifier(ctx context.Context, id, param byte, state ...byte) ([]byte, error) {
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: INPUT_OUTPUT_CONTROL_BY_LOCAL_IDENTIFIER, Data: append([]byte{id, param}, state...)})
+	if err != nil {
+		return nil, fmt.Errorf("InputOutputControlByLocalIdentifier: %w", err)
+	}
+	if len(reply.Data) < 2 || reply.Data[0] != id || reply.Data[1] != param {
+		return nil, fmt.Errorf("InputOutputControlByLocalIdentifier: unexpected response %X", reply.Data)
+	}
+	return reply.Data[2:], nil
+}
+
+// ActivateOutput takes control of an output, state is the duty cycle in percent for PWM outputs and on/off for others
+func (t *Client) ActivateOutput(ctx context.Context, a Actuator, state byte) error {
+	if !a.PWM && state > 1 {
+		state = 1
+	}
+	if a.PWM && state > 100 {
+		return fmt.Errorf("ActivateOutput: %s duty cycle %d%% out of range", a.Name, state)
+	}
+	_, err := t.InputOutputControlByLocalIdentifier(ctx, a.ID, IO_SHORT_TERM_ADJUSTMENT, state)
+	return err
+}
+
+// ReturnControlToECU hands an output back to the ECU
+func (t *Client) ReturnControlToECU(ctx context.Context, a Actuator) error {
+	_, err := t.InputOutputControlByLocalIdentifier(ctx, a.ID, IO_RETURN_CONTROL_TO_ECU)
+	return err
+}
@@ -0,0 +1,68 @@
+package kwp2000
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionLost is sent by KeepAlive when the ECU stops answering TesterPresent
+var ErrSessionLost = errors.New("session lost")
+
+// testerPresent is sent without the optional responseRequired parameter, the ECU then always answers.
+// This is the request the simulator answers and the one the T8 client sends
+func testerPresent() *KWPRequest {
+	return &KWPRequest{ServiceID: TESTER_PRESENT}
+}
+
+// TesterPresent tells the ECU the tester is still connected
+func (t *Client) TesterPresent(ctx context.Context) error {
+	if _, err := t.SendRequest(ctx, testerPresent()); err != nil {
+		return fmt.Errorf("TesterPresent: %w", err)
+	}
+	return nil
+}
+
+// KeepAlive sends TesterPresent whenever the bus has been idle for interval, until ctx is done.
+// TesterPresent is never interleaved with other requests, it is skipped while one is in progress.
+// The returned channel gets ErrSessionLost and is closed if the ECU stops answering
+func (t *Client) KeepAlive(ctx context.Context, interval time.Duration) <-chan error {
+	lost := make(chan error, 1)
+	go func() {
+		defer close(lost)
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		failures := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !t.mu.TryLock() {
+					continue
+				}
+				if time.Since(t.lastActivity) < interval {
+					t.mu.Unlock()
+					continue
+				}
+				_, err := t.sendRequest(ctx, testerPresent())
+				t.lastActivity = time.Now()
+				t.mu.Unlock()
+				if err == nil {
+					failures = 0
+					continue
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				// one lost frame is not a lost session
+				if failures++; failures >= 2 {
+					lost <- fmt.Errorf("%w: %v", ErrSessionLost, err)
+					return
+				}
+			}
+		}
+	}()
+	return lost
+}
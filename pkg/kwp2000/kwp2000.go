@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sync"
 	"time"
 
 	"github.com/avast/retry-go/v4"
@@ -18,35 +19,95 @@ type Client struct {
 	//canID             uint32
 	//recvID            []uint32
 
-	responseID        uint32
-	defaultTimeout    time.Duration
+	initID          uint32
+	initRespID      uint32
+	reqID           uint32
+	reqChunkConfID  uint32
+	respChunkConfID uint32
+	responseID      uint32
+
+	defaultTimeout  time.Duration
+	readDataTimeout time.Duration
+	chunkTimeout    time.Duration
+	jumpTimeout     time.Duration
+	recvTimeout     time.Duration
+	pendingTimeout  time.Duration
+
 	gotSequrityAccess bool
+	keys              *KeyRegistry
+	softwareVersion   string
+	attemptsMu        sync.Mutex
+	attempts          []SecurityAttempt
+
+	// mu is held for the duration of a request so the keep-alive never interleaves with it
+	mu           sync.Mutex
+	lastActivity time.Time
 }
 
+// KWPRequest is a request to a service, Data holds everything after the service id
 type KWPRequest struct {
+	ServiceID byte
+	Data      []byte
+	// Timeout to wait for the first response frame, defaults to the client timeout
+	Timeout time.Duration
 }
 
+// KWPReply is a reassembled positive response, Data holds everything after the service id
 type KWPReply struct {
+	ServiceID byte
+	Data      []byte
+}
+
+func New(c *gocan.Client, opts ...Option) *Client {
+	t := &Client{
+		c:               c,
+		initID:          INIT_MSG_ID,
+		initRespID:      INIT_RESP_ID,
+		reqID:           REQ_MSG_ID,
+		reqChunkConfID:  REQ_CHUNK_CONF_ID,
+		respChunkConfID: RESP_CHUNK_CONF_ID,
+		responseID:      0x258,
+		defaultTimeout:  250 * time.Millisecond,
+		readDataTimeout: 50 * time.Millisecond,
+		chunkTimeout:    450 * time.Millisecond,
+		pendingTimeout:  responsePendingTimeout,
+		keys:            DefaultKeyRegistry,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.jumpTimeout == 0 {
+		t.jumpTimeout = t.defaultTimeout * 3
+	}
+	if t.recvTimeout == 0 {
+		t.recvTimeout = t.defaultTimeout * 4
+	}
+	return t
 }
 
-func New(c *gocan.Client /*canID uint32, recvID ...uint32*/) *Client {
-	return &Client{
-		c: c,
-		//canID:          canID,
-		//recvID:         recvID,
-		defaultTimeout: 250 * time.Millisecond,
+// busy locks the bus for a request, call the returned func when done
+func (t *Client) busy() func() {
+	t.mu.Lock()
+	return func() {
+		t.lastActivity = time.Now()
+		t.mu.Unlock()
 	}
 }
 
-func (t *Client) StartSession(ctx context.Context, id, responseID uint32) error {
-	payload := []byte{0x3F, START_COMMUNICATION, 0x00, 0x11, byte(REQ_MSG_ID >> 8), byte(REQ_MSG_ID), 0x00, 0x00}
-	frame := gocan.NewFrame(id, payload, gocan.ResponseRequired)
-	resp, err := t.c.SendAndPoll(ctx, frame, t.defaultTimeout, responseID)
+// StartSession starts a session on the init id and learns the response id from the reply
+func (t *Client) StartSession(ctx context.Context) error {
+	payload := []byte{0x3F, START_COMMUNICATION, 0x00, 0x11, byte(t.reqID >> 8), byte(t.reqID), 0x00, 0x00}
+	frame := gocan.NewFrame(t.initID, payload, gocan.ResponseRequired)
+	defer t.busy()()
+	resp, err := t.c.SendAndPoll(ctx, frame, t.defaultTimeout, t.initRespID)
 	if err != nil {
 		return fmt.Errorf("StartSession: %w", err)
 	}
 
 	data := resp.Data()
+	if err := checkFrame(data); err != nil {
+		return fmt.Errorf("StartSession: %w", err)
+	}
 	if data[3] != START_COMMUNICATION|0x40 {
 		return fmt.Errorf("StartSession: %w", TranslateErrorCode(GENERAL_REJECT))
 	}
@@ -60,169 +121,59 @@ func (t *Client) StartSession(ctx context.Context, id, responseID uint32) error
 
 func (t *Client) StopSession(ctx context.Context) error {
 	payload := []byte{0x40, 0xA1, 0x02, STOP_COMMUNICATION, 0x00, 0x00, 0x00, 0x00}
-	frame := gocan.NewFrame(REQ_MSG_ID, payload, gocan.ResponseRequired)
+	frame := gocan.NewFrame(t.reqID, payload, gocan.ResponseRequired)
+	defer t.busy()()
 	return t.c.Send(frame)
 }
 
 func (t *Client) StartRoutineByIdentifier(ctx context.Context, id byte) error {
-	frame := gocan.NewFrame(REQ_MSG_ID, []byte{0x40, 0xA1, 0x03, START_ROUTINE_BY_LOCAL_IDENTIFIER, id, 0x10, 0x00, 0x00}, gocan.ResponseRequired)
-	resp, err := t.c.SendAndPoll(ctx, frame, 250*time.Millisecond, t.responseID)
-	if err != nil {
+	if _, err := t.SendRequest(ctx, &KWPRequest{ServiceID: START_ROUTINE_BY_LOCAL_IDENTIFIER, Data: []byte{id, 0x10}}); err != nil {
 		return fmt.Errorf("StartRoutineByIdentifier: %w", err)
 	}
-	d := resp.Data()
-	if d[3] == 0x7F {
-		return fmt.Errorf("StartRoutineByIdentifier: %w", TranslateErrorCode(d[5]))
-	}
-
 	return nil
 }
 
 func (t *Client) StopRoutineByIdentifier(ctx context.Context, id byte) ([]byte, error) {
-	frame := gocan.NewFrame(REQ_MSG_ID, []byte{0x40, 0xA1, 0x02, STOP_ROUTINE_BY_LOCAL_IDENTIFIER, id, 0x00, 0x00, 0x00}, gocan.ResponseRequired)
-	log.Println(frame.String())
-	resp, err := t.c.SendAndPoll(ctx, frame, 250*time.Millisecond, t.responseID)
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: STOP_ROUTINE_BY_LOCAL_IDENTIFIER, Data: []byte{id}})
 	if err != nil {
 		return nil, fmt.Errorf("StopRoutineByIdentifier: %w", err)
 	}
-	log.Println(resp.String())
-	d := resp.Data()
-	if d[3] == 0x7F {
-		return nil, fmt.Errorf("StopRoutineByIdentifier: %w", TranslateErrorCode(d[5]))
-	}
-	return d, nil
+	return reply.Data, nil
 }
 
 func (t *Client) RequestRoutineResultsByLocalIdentifier(ctx context.Context, id byte) ([]byte, error) {
-	frame := gocan.NewFrame(REQ_MSG_ID, []byte{0x40, 0xA1, 0x02, REQUEST_ROUTINE_RESULTS_BY_LOCAL_IDENTIFIER, id}, gocan.ResponseRequired)
-	log.Println(frame.String())
-	resp, err := t.c.SendAndPoll(ctx, frame, 250*time.Millisecond, t.responseID)
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: REQUEST_ROUTINE_RESULTS_BY_LOCAL_IDENTIFIER, Data: []byte{id}})
 	if err != nil {
 		return nil, fmt.Errorf("RequestRoutineResultsByLocalIdentifier: %w", err)
 	}
-
-	log.Println(resp.String())
-
-	d := resp.Data()
-	if d[3] == 0x7F {
-		return nil, fmt.Errorf("RequestRoutineResultsByLocalIdentifier: %w", TranslateErrorCode(d[5]))
-	}
-	return d, nil
+	return reply.Data, nil
 }
 
 func (t *Client) ReadDataByLocalIdentifier2(ctx context.Context, id, mode byte) ([]byte, error) {
-	frame := gocan.NewFrame(REQ_MSG_ID, []byte{0x40, 0xA1, 0x03, READ_DATA_BY_LOCAL_IDENTIFIER, id, mode}, gocan.ResponseRequired)
-	log.Println(frame.String())
-	resp, err := t.c.SendAndPoll(ctx, frame, 250*time.Millisecond, t.responseID)
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: READ_DATA_BY_LOCAL_IDENTIFIER, Data: []byte{id, mode}})
 	if err != nil {
 		return nil, fmt.Errorf("ReadDataByLocalIdentifier2: %w", err)
 	}
-
-	out := bytes.NewBuffer(nil)
-
-	log.Println(resp.String())
-
-	d := resp.Data()
-	if d[3] == 0x7F {
-		return nil, fmt.Errorf("ReadDataByLocalIdentifier2: %w", TranslateErrorCode(d[5]))
-	}
-
-	dataLenLeft := d[2] - 2
-	//log.Println(resp.String())
-	//log.Printf("data len left: %d", dataLenLeft)
-
-	var thisRead byte
-	if dataLenLeft > 3 {
-		thisRead = 3
-	} else {
-		thisRead = dataLenLeft
+	// first byte is the local identifier echoed back
+	if len(reply.Data) < 1 {
+		return nil, errors.New("ReadDataByLocalIdentifier2: empty response")
 	}
-
-	out.Write(d[5 : 5+thisRead])
-	dataLenLeft -= thisRead
-
-	//log.Printf("data len left: %d", dataLenLeft)
-	//log.Println(resp.String())
-
-	currentChunkNumber := d[0] & 0x3F
-
-	for currentChunkNumber != 0 {
-		//log.Printf("current chunk %02X", currentChunkNumber)
-		frame := gocan.NewFrame(RESP_CHUNK_CONF_ID, []byte{0x40, 0xA1, 0x3F, d[0] &^ 0x40, 0x00, 0x00, 0x00, 0x00}, gocan.ResponseRequired)
-		//log.Println(frame.String())
-		resp, err := t.c.SendAndPoll(ctx, frame, 250*time.Millisecond, t.responseID)
-		if err != nil {
-			return nil, err
-		}
-		d = resp.Data()
-
-		toRead := uint8(math.Min(6, float64(dataLenLeft)))
-		//log.Println("bytes to read", toRead)
-		out.Write(d[2 : 2+toRead])
-		dataLenLeft -= toRead
-		//log.Printf("data len left: %d", dataLenLeft)
-		currentChunkNumber = d[0] & 0x3F
-		//log.Printf("next chunk %02X", currentChunkNumber)
-	}
-
-	return out.Bytes(), nil
+	return reply.Data[1:], nil
 }
 
 func (t *Client) ReadDataByLocalIdentifier(ctx context.Context, id byte) ([]byte, error) {
-	frame := gocan.NewFrame(REQ_MSG_ID, []byte{0x40, 0xA1, 0x02, READ_DATA_BY_LOCAL_IDENTIFIER, id, 0x00, 0x00, 0x00}, gocan.ResponseRequired)
-	resp, err := t.c.SendAndPoll(ctx, frame, 50*time.Millisecond, t.responseID)
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: READ_DATA_BY_LOCAL_IDENTIFIER, Data: []byte{id}, Timeout: t.readDataTimeout})
 	if err != nil {
 		return nil, fmt.Errorf("ReadDataByLocalIdentifier: %w", err)
 	}
-	out := bytes.NewBuffer(nil)
-
-	d := resp.Data()
-	if d[3] == 0x7F {
-		return nil, fmt.Errorf("ReadDataByLocalIdentifier: %w", TranslateErrorCode(d[5]))
-	}
-
-	dataLenLeft := d[2] - 2
-	//log.Println(resp.String())
-	//log.Printf("data len left: %d", dataLenLeft)
-
-	var thisRead byte
-	if dataLenLeft > 3 {
-		thisRead = 3
-	} else {
-		thisRead = dataLenLeft
+	if len(reply.Data) < 1 {
+		return nil, errors.New("ReadDataByLocalIdentifier: empty response")
 	}
-
-	out.Write(d[5 : 5+thisRead])
-	dataLenLeft -= thisRead
-
-	//log.Printf("data len left: %d", dataLenLeft)
-	//log.Println(resp.String())
-
-	currentChunkNumber := d[0] & 0x3F
-
-	for currentChunkNumber != 0 {
-		//log.Printf("current chunk %02X", currentChunkNumber)
-		frame := gocan.NewFrame(RESP_CHUNK_CONF_ID, []byte{0x40, 0xA1, 0x3F, d[0] &^ 0x40, 0x00, 0x00, 0x00, 0x00}, gocan.ResponseRequired)
-		//log.Println(frame.String())
-		resp, err := t.c.SendAndPoll(ctx, frame, 450*time.Millisecond, t.responseID)
-		if err != nil {
-			return nil, err
-		}
-		d = resp.Data()
-
-		toRead := uint8(math.Min(6, float64(dataLenLeft)))
-		//log.Println("bytes to read", toRead)
-		out.Write(d[2 : 2+toRead])
-		dataLenLeft -= toRead
-		//log.Printf("data len left: %d", dataLenLeft)
-		currentChunkNumber = d[0] & 0x3F
-		//log.Printf("next chunk %02X", currentChunkNumber)
-	}
-
-	return out.Bytes(), nil
+	return reply.Data[1:], nil
 }
 
 func (t *Client) TransferData(ctx context.Context) ([]byte, error) {
+	defer t.busy()()
 	buff := bytes.NewBuffer(nil)
 	for {
 		b, err := t.transferData(ctx)
@@ -237,13 +188,15 @@ func (t *Client) TransferData(ctx context.Context) ([]byte, error) {
 			t.Ack(b[0], gocan.Outgoing)
 			break
 		}
-		toRead := b[2]
+		toRead := int(b[2])
 		//log.Printf("toRead %d, %02X", toRead, b[0])
-		if toRead > 3 {
+		if toRead > 4 {
 			buff.WriteByte(b[7])
 			toRead -= 5
+		} else {
+			toRead = 0
 		}
-		sub := t.c.Subscribe(ctx, 0x258)
+		sub := t.c.Subscribe(ctx, t.responseID)
 		if err := t.Ack(b[0], gocan.ResponseRequired); err != nil {
 			return nil, err
 		}
@@ -252,23 +205,27 @@ func (t *Client) TransferData(ctx context.Context) ([]byte, error) {
 			select {
 			case f := <-sub:
 				d := f.Data()
+				if err := checkNextFrame(b[0], d); err != nil {
+					return nil, fmt.Errorf("TransferData: %w", err)
+				}
+				b = d
 				//log.Printf("toRead %d, %X", toRead, d)
 				var readThis int
 				if toRead > 6 {
 					readThis = 6
 				} else {
-					readThis = int(toRead)
+					readThis = toRead
 				}
 
 				buff.Write(d[2 : 2+readThis])
-				toRead -= byte(readThis)
+				toRead -= readThis
 				if d[0] == 0x80 || d[0] == 0xC0 {
 					t.Ack(d[0], gocan.Outgoing)
 					break outer
 				} else {
 					t.Ack(d[0], gocan.ResponseRequired)
 				}
-			case <-time.After(250 * time.Millisecond):
+			case <-time.After(t.defaultTimeout):
 				return nil, fmt.Errorf("timeout")
 			}
 		}
@@ -277,44 +234,45 @@ func (t *Client) TransferData(ctx context.Context) ([]byte, error) {
 }
 
 func (t *Client) transferData(ctx context.Context) ([]byte, error) {
-	frame := gocan.NewFrame(REQ_MSG_ID, []byte{0x40, 0xA1, 0x01, TRANSFER_DATA}, gocan.ResponseRequired)
+	frame := gocan.NewFrame(t.reqID, []byte{0x40, 0xA1, 0x01, TRANSFER_DATA}, gocan.ResponseRequired)
 	//	log.Println(frame.String())
-	resp, err := t.c.SendAndPoll(ctx, frame, 250*time.Millisecond, t.responseID)
+	resp, err := t.c.SendAndPoll(ctx, frame, t.defaultTimeout, t.responseID)
 	if err != nil {
 		return nil, fmt.Errorf("TransferData: %w", err)
 	}
+	if resp, err = t.waitPending(ctx, resp); err != nil {
+		return nil, fmt.Errorf("TransferData: %w", err)
+	}
 
 	d := resp.Data()
+	if err := checkFrame(d); err != nil {
+		return nil, fmt.Errorf("TransferData: %w", err)
+	}
 	if d[3] == 0x7F {
-		return nil, fmt.Errorf("TransferData: %w", TranslateErrorCode(d[5]))
+		return nil, fmt.Errorf("TransferData: %w", newNegativeResponseError(d))
+	}
+	if d[0]&0x40 == 0 {
+		return nil, fmt.Errorf("TransferData: %w: expected first frame, got %02X", ErrInvalidFrame, d[0])
 	}
 	return d, nil
 }
 
 func (t *Client) RequestTransferExit(ctx context.Context) error {
-	frame := gocan.NewFrame(REQ_MSG_ID, []byte{0x40, 0xA1, 0x01, REQUEST_TRANSFER_EXIT}, gocan.ResponseRequired)
-	//	log.Println(frame.String())
-	resp, err := t.c.SendAndPoll(ctx, frame, 250*time.Millisecond, t.responseID)
-	if err != nil {
+	if _, err := t.SendRequest(ctx, &KWPRequest{ServiceID: REQUEST_TRANSFER_EXIT}); err != nil {
 		return fmt.Errorf("RequestTransferExit: %w", err)
 	}
-
-	//	log.Println(resp.String())
-
-	d := resp.Data()
-	if d[3] == 0x7F {
-		return fmt.Errorf("RequestTransferExit: %w", TranslateErrorCode(d[5]))
-	}
-
-	if d[3] != 0x77 {
-		return fmt.Errorf("RequestTransferExit: expected 0x77, got %02X", d[3])
-	}
 	return nil
 }
 
 func (t *Client) DynamicallyDefineLocalIdRequest(ctx context.Context, id int, v *VarDefinition) error {
+	return t.DefineLocalIdentifier(ctx, firstDynamicLocalID, id, v)
+}
+
+// DefineLocalIdentifier defines v at position id of the dynamically defined local identifier localID,
+// defining position 0 clears the identifier
+func (t *Client) DefineLocalIdentifier(ctx context.Context, localID byte, id int, v *VarDefinition) error {
 	buff := bytes.NewBuffer(nil)
-	buff.WriteByte(0xF0)
+	buff.WriteByte(localID)
 	switch v.Method {
 	case VAR_METHOD_ADDRESS:
 		buff.Write([]byte{0x03, byte(id), uint8(v.Length), byte(v.Value >> 16), byte(v.Value >> 8), byte(v.Value)})
@@ -325,109 +283,282 @@ func (t *Client) DynamicallyDefineLocalIdRequest(ctx context.Context, id int, v
 	}
 
 	message := append([]byte{byte(buff.Len()), DYNAMICALLY_DEFINE_LOCAL_IDENTIFIER}, buff.Bytes()...)
+	defer t.busy()()
 	for _, msg := range t.splitRequest(message) {
 		if msg.Type().Type == 1 {
 			if err := t.c.Send(msg); err != nil {
 				return err
 			}
 		} else {
-			resp, err := t.c.SendAndPoll(ctx, msg, t.defaultTimeout, REQ_CHUNK_CONF_ID)
+			resp, err := t.c.SendAndPoll(ctx, msg, t.defaultTimeout, t.reqChunkConfID)
 			if err != nil {
 				return fmt.Errorf("DynamicallyDefineLocalIdRequest: %w", err)
 			}
-			if err := TranslateErrorCode(resp.Data()[3+2]); err != nil {
-				return fmt.Errorf("DynamicallyDefineLocalIdRequest: %w", err)
+			if d := resp.Data(); len(d) > 5 && d[5] != 0x00 {
+				return fmt.Errorf("DynamicallyDefineLocalIdRequest: %w", &NegativeResponseError{ServiceID: DYNAMICALLY_DEFINE_LOCAL_IDENTIFIER, Code: d[5], Frame: d})
 			}
 		}
 	}
 	return nil
 }
 
+// RequestSecurityAccess tries the key algorithms of the registry until the ECU grants access,
+// starting with the one remembered for the software version of the ECU. The outcome of
+// every algorithm tried is returned by SecurityAttempts afterwards
 func (t *Client) RequestSecurityAccess(ctx context.Context, force bool) (bool, error) {
 	if t.gotSequrityAccess && !force {
 		return true, nil
 	}
-	for i := 0; i <= 4; i++ {
-		ok, err := t.letMeIn(ctx, i)
+	if t.softwareVersion == "" {
+		if data, err := t.ReadECUIdentificationOption(ctx, ID_SOFTWARE_VERSION); err == nil {
+			t.softwareVersion = identString(data)
+		}
+	}
+	t.setAttempts(nil)
+	var lastErr error
+	for _, alg := range t.keys.Algorithms(t.softwareVersion) {
+		start := time.Now()
+		seed, key, err := t.letMeIn(ctx, alg)
+		t.addAttempt(SecurityAttempt{Algorithm: alg.Name(), Seed: seed, Key: key, Duration: time.Since(start), Err: err})
 		if err != nil {
-			log.Printf("/!\\ Failed to obtain security access: %v", err)
-			time.Sleep(3 * time.Second)
+			lastErr = err
+			log.Printf("/!\\ Failed to obtain security access using %s: %v", alg.Name(), err)
+			if ctx.Err() != nil {
+				break
+			}
+			// a wrong key moves straight on to the next algorithm, anything else waits for the ECU
+			if !errors.Is(err, ErrInvalidKey) {
+				select {
+				case <-time.After(3 * time.Second):
+				case <-ctx.Done():
+					return false, fmt.Errorf("RequestSecurityAccess: %w", ctx.Err())
+				}
+			}
 			continue
 		}
-		if ok {
-			t.gotSequrityAccess = true
-			return true, nil
-		}
+		t.gotSequrityAccess = true
+		t.keys.Learn(t.softwareVersion, alg.Name())
+		return true, nil
+	}
+	if lastErr != nil {
+		return false, fmt.Errorf("RequestSecurityAccess: access was not granted: %w", lastErr)
 	}
-
 	return false, errors.New("RequestSecurityAccess: access was not granted")
 }
 
-func (t *Client) letMeIn(ctx context.Context, method int) (bool, error) {
+// SecurityAttempts returns the algorithms tried by the last RequestSecurityAccess and their outcome
+func (t *Client) SecurityAttempts() []SecurityAttempt {
+	t.attemptsMu.Lock()
+	defer t.attemptsMu.Unlock()
+	return append([]SecurityAttempt(nil), t.attempts...)
+}
+
+func (t *Client) setAttempts(a []SecurityAttempt) {
+	t.attemptsMu.Lock()
+	defer t.attemptsMu.Unlock()
+	t.attempts = a
+}
+
+func (t *Client) addAttempt(a SecurityAttempt) {
+	t.attemptsMu.Lock()
+	defer t.attemptsMu.Unlock()
+	t.attempts = append(t.attempts, a)
+}
+
+func (t *Client) letMeIn(ctx context.Context, alg KeyAlgorithm) (seed, key uint16, err error) {
+	defer t.busy()()
+	err = retryBusy(ctx, func() error {
+		seed, key, err = t.letMeInOnce(ctx, alg)
+		return err
+	})
+	return seed, key, err
+}
+
+// letMeInOnce requests a seed and sends the key alg calculates for it, a nil error means access was granted
+func (t *Client) letMeInOnce(ctx context.Context, alg KeyAlgorithm) (uint16, uint16, error) {
 	msg := []byte{0x40, 0xA1, 0x02, 0x27, 0x05, 0x00, 0x00, 0x00}
 	msgReply := []byte{0x40, 0xA1, 0x04, 0x27, 0x06, 0x00, 0x00, 0x00}
 
-	f, err := t.c.SendAndPoll(ctx, gocan.NewFrame(REQ_MSG_ID, msg, gocan.ResponseRequired), t.defaultTimeout, t.responseID)
+	f, err := t.c.SendAndPoll(ctx, gocan.NewFrame(t.reqID, msg, gocan.ResponseRequired), t.defaultTimeout, t.responseID)
+	if err == nil {
+		f, err = t.waitPending(ctx, f)
+	}
 	if err != nil {
-		return false, fmt.Errorf("request seed: %v", err)
+		return 0, 0, fmt.Errorf("request seed: %v", err)
 
 	}
 	d := f.Data()
+	if err := checkFrame(d); err != nil {
+		return 0, 0, fmt.Errorf("request seed: %w", err)
+	}
 	t.Ack(d[0], gocan.ResponseRequired)
+	if d[3] == 0x7F {
+		return 0, 0, fmt.Errorf("request seed: %w", newNegativeResponseError(d))
+	}
+	if d[3] != 0x67 || d[4] != 0x05 {
+		return 0, 0, fmt.Errorf("request seed: unexpected response %X", d)
+	}
 
-	s := int(d[5])<<8 | int(d[6])
-	k := calcen(s, method)
+	seed := uint16(d[5])<<8 | uint16(d[6])
+	key := alg.Key(seed)
 
-	msgReply[5] = byte(int(k) >> 8 & int(0xFF))
-	msgReply[6] = byte(k) & 0xFF
+	msgReply[5] = byte(key >> 8)
+	msgReply[6] = byte(key)
 
-	f2, err := t.c.SendAndPoll(ctx, gocan.NewFrame(REQ_MSG_ID, msgReply, gocan.ResponseRequired), t.defaultTimeout, t.responseID)
+	f2, err := t.c.SendAndPoll(ctx, gocan.NewFrame(t.reqID, msgReply, gocan.ResponseRequired), t.defaultTimeout, t.responseID)
+	if err == nil {
+		f2, err = t.waitPending(ctx, f2)
+	}
 	if err != nil {
-		return false, fmt.Errorf("send seed: %v", err)
+		return seed, key, fmt.Errorf("send key: %v", err)
 
 	}
 	d2 := f2.Data()
+	if err := checkFrame(d2); err != nil {
+		return seed, key, fmt.Errorf("send key: %w", err)
+	}
 	t.Ack(d2[0], gocan.ResponseRequired)
+	if d2[3] == 0x7F {
+		return seed, key, fmt.Errorf("send key: %w", newNegativeResponseError(d2))
+	}
 	if d2[3] == 0x67 && d2[5] == 0x34 {
-		return true, nil
-	} else {
-		log.Println(f2.String())
-		return false, errors.New("invalid response")
+		return seed, key, nil
 	}
+	log.Println(f2.String())
+	return seed, key, fmt.Errorf("send key: unexpected response %X", d2)
 }
 
 // 266h Send acknowledgement, has 0x3F on 3rd!
 func (t *Client) Ack(val byte, typ gocan.CANFrameType) error {
 	ack := []byte{0x40, 0xA1, 0x3F, val & 0xBF, 0x00, 0x00, 0x00, 0x00}
-	return t.c.Send(gocan.NewFrame(0x266, ack, typ))
+	return t.c.Send(gocan.NewFrame(t.respChunkConfID, ack, typ))
+}
+
+// SendRequest sends a request to the ECU, splitting it over several frames if needed,
+// and returns the reassembled positive response. Negative responses are returned as errors
+func (t *Client) SendRequest(ctx context.Context, req *KWPRequest) (*KWPReply, error) {
+	defer t.busy()()
+	return t.sendRequest(ctx, req)
 }
 
-func calcen(seed int, method int) int {
-	key := seed << 2
-	key &= 0xFFFF
-	switch method {
-	case 0:
-		key ^= 0x8142
-		key -= 0x2356
-	case 1:
-		key ^= 0x4081
-		key -= 0x1F6F
-	case 2:
-		key ^= 0x3DC
-		key -= 0x2356
-	case 3:
-		key ^= 0x3D7
-		key -= 0x2356
-	case 4:
-		key ^= 0x409
-		key -= 0x2356
+const (
+	// how long to wait for the real response after the ECU answered response pending
+	responsePendingTimeout = 5 * time.Second
+	// busy repeat request is retried this many times, the delay doubles between each attempt
+	busyRepeatAttempts = 4
+	busyRepeatDelay    = 50 * time.Millisecond
+)
+
+// retryBusy calls fn again with backoff for as long as the ECU answers busy repeat request
+func retryBusy(ctx context.Context, fn func() error) error {
+	delay := busyRepeatDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !errors.Is(err, ErrBusyRepeatRequest) || attempt == busyRepeatAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
 	}
-	key &= 0xFFFF
-	return key
 }
 
-func (t *Client) SendRequest(req *KWPRequest) (*KWPReply, error) {
-	return nil, nil
+func (t *Client) sendRequest(ctx context.Context, req *KWPRequest) (reply *KWPReply, err error) {
+	err = retryBusy(ctx, func() error {
+		reply, err = t.sendRequestOnce(ctx, req)
+		return err
+	})
+	return reply, err
+}
+
+// waitPending keeps polling for the real response for as long as the ECU answers response pending
+func (t *Client) waitPending(ctx context.Context, f gocan.CANFrame) (gocan.CANFrame, error) {
+	for {
+		d := f.Data()
+		if len(d) < 6 || d[3] != 0x7F || d[5] != REQUEST_CORRECTLY_RECEIVED_RESPONSE_PENDING {
+			return f, nil
+		}
+		var err error
+		f, err = t.c.Poll(ctx, t.pendingTimeout, t.responseID)
+		if err != nil {
+			return nil, fmt.Errorf("response pending: %w", err)
+		}
+	}
+}
+
+func (t *Client) sendRequestOnce(ctx context.Context, req *KWPRequest) (*KWPReply, error) {
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = t.defaultTimeout
+	}
+
+	payload := append([]byte{byte(len(req.Data) + 1), req.ServiceID}, req.Data...)
+	frames := t.splitRequest(payload)
+	for _, msg := range frames[:len(frames)-1] {
+		resp, err := t.c.SendAndPoll(ctx, msg, t.defaultTimeout, t.reqChunkConfID)
+		if err != nil {
+			return nil, err
+		}
+		if d := resp.Data(); len(d) > 5 && d[5] != 0x00 {
+			return nil, &NegativeResponseError{ServiceID: req.ServiceID, Code: d[5], Frame: d}
+		}
+	}
+	last := gocan.NewFrame(t.reqID, frames[len(frames)-1].Data(), gocan.ResponseRequired)
+	resp, err := t.c.SendAndPoll(ctx, last, timeout, t.responseID)
+	if err != nil {
+		return nil, err
+	}
+	if resp, err = t.waitPending(ctx, resp); err != nil {
+		return nil, err
+	}
+
+	d := resp.Data()
+	if err := checkFrame(d); err != nil {
+		return nil, err
+	}
+	if d[3] == 0x7F {
+		return nil, newNegativeResponseError(d)
+	}
+	if d[0]&0x40 == 0 {
+		return nil, fmt.Errorf("%w: expected first frame, got %02X", ErrInvalidFrame, d[0])
+	}
+	if d[3] != req.ServiceID|0x40 {
+		return nil, fmt.Errorf("unexpected response %02X to service %02X", d[3], req.ServiceID)
+	}
+
+	reply := &KWPReply{ServiceID: d[3]}
+	// length includes the service id
+	dataLenLeft := int(d[2]) - 1
+	if dataLenLeft < 0 {
+		return nil, fmt.Errorf("%w: invalid response length: %X", ErrInvalidFrame, d)
+	}
+	thisRead := int(math.Min(4, float64(dataLenLeft)))
+	reply.Data = append(reply.Data, d[4:4+thisRead]...)
+	dataLenLeft -= thisRead
+
+	for !lastFrame(d) {
+		frame := gocan.NewFrame(t.respChunkConfID, []byte{0x40, 0xA1, 0x3F, d[0] &^ 0x40, 0x00, 0x00, 0x00, 0x00}, gocan.ResponseRequired)
+		resp, err := t.c.SendAndPoll(ctx, frame, t.chunkTimeout, t.responseID)
+		if err != nil {
+			return nil, err
+		}
+		prev := d[0]
+		d = resp.Data()
+		if err := checkNextFrame(prev, d); err != nil {
+			return nil, err
+		}
+		toRead := int(math.Min(6, float64(dataLenLeft)))
+		reply.Data = append(reply.Data, d[2:2+toRead]...)
+		dataLenLeft -= toRead
+	}
+	if dataLenLeft > 0 {
+		return nil, fmt.Errorf("%w: response ended %d bytes short", ErrInvalidFrame, dataLenLeft)
+	}
+
+	return reply, nil
 }
 
 func (t *Client) splitRequest(payload []byte) []gocan.CANFrame {
@@ -464,9 +595,9 @@ func (t *Client) splitRequest(payload []byte) []gocan.CANFrame {
 		}
 
 		if flag&0x80 == 0x80 {
-			results = append(results, gocan.NewFrame(REQ_MSG_ID, msgData, gocan.ResponseRequired))
+			results = append(results, gocan.NewFrame(t.reqID, msgData, gocan.ResponseRequired))
 		} else {
-			results = append(results, gocan.NewFrame(REQ_MSG_ID, msgData, gocan.Outgoing))
+			results = append(results, gocan.NewFrame(t.reqID, msgData, gocan.Outgoing))
 		}
 
 	}
@@ -476,23 +607,46 @@ func (t *Client) splitRequest(payload []byte) []gocan.CANFrame {
 
 func (t *Client) recvData(ctx context.Context, length int) ([]byte, error) {
 	var receivedBytes, payloadLeft int
+	var prev []byte
 	out := bytes.NewBuffer([]byte{})
 
 	sub := t.c.Subscribe(ctx, t.responseID)
-	startTransfer := gocan.NewFrame(REQ_MSG_ID, []byte{0x40, 0xA1, 0x02, 0x21, 0xF0, 0x00, 0x00, 0x00}, gocan.ResponseRequired)
+	startTransfer := gocan.NewFrame(t.reqID, []byte{0x40, 0xA1, 0x02, 0x21, 0xF0, 0x00, 0x00, 0x00}, gocan.ResponseRequired)
 	if err := t.c.Send(startTransfer); err != nil {
 		return nil, err
 	}
 
+	timeout := t.recvTimeout
 outer:
 	for receivedBytes < length {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
-		case <-time.After(t.defaultTimeout * 4):
+		case <-time.After(timeout):
 			return nil, fmt.Errorf("timeout")
 		case f := <-sub:
 			d := f.Data()
+			if err := checkFrame(d); err != nil {
+				return nil, err
+			}
+			if d[0]&0x40 == 0x40 && d[3] == 0x7F {
+				if d[5] == REQUEST_CORRECTLY_RECEIVED_RESPONSE_PENDING {
+					timeout = t.pendingTimeout
+					continue
+				}
+				return nil, newNegativeResponseError(d)
+			}
+			timeout = t.recvTimeout
+			if d[0]&0x40 == 0x40 {
+				if prev != nil || d[3] != READ_DATA_BY_LOCAL_IDENTIFIER|0x40 {
+					return nil, fmt.Errorf("%w: unexpected first frame %X", ErrInvalidFrame, d)
+				}
+			} else if prev == nil {
+				return nil, fmt.Errorf("%w: consecutive frame %02X before first frame", ErrInvalidFrame, d[0])
+			} else if err := checkNextFrame(prev[0], d); err != nil {
+				return nil, err
+			}
+			prev = d
 			if d[0]&0x40 == 0x40 {
 				payloadLeft = int(d[2]) - 2 // subtract two non-payload bytes
 				if payloadLeft > 0 && receivedBytes < length {
@@ -562,9 +716,14 @@ func (t *Client) ReadFlash(ctx context.Context, addr, length int) ([]byte, error
 					log.Printf("Failed to read memory by address, pos: 0x%X, length: 0x%X, retrying: %v", readPos, readLength, err)
 				}),
 				retry.LastErrorOnly(true),
+				retry.RetryIf(func(err error) bool {
+					// a negative response like security access denied will not go away by asking again
+					var nrc *NegativeResponseError
+					return !errors.As(err, &nrc) || nrc.Temporary()
+				}),
 			)
 			if err != nil {
-				return nil, fmt.Errorf("failed to read memory by address, pos: 0x%X, length: 0x%X", readPos, readLength)
+				return nil, fmt.Errorf("failed to read memory by address, pos: 0x%X, length: 0x%X: %w", readPos, readLength, err)
 			}
 			readPos += readLength
 		}
@@ -573,20 +732,35 @@ func (t *Client) ReadFlash(ctx context.Context, addr, length int) ([]byte, error
 	return out.Bytes(), nil
 }
 
-func (t *Client) ReadMemoryByAddress(ctx context.Context, address, length int) ([]byte, error) {
+func (t *Client) ReadMemoryByAddress(ctx context.Context, address, length int) (data []byte, err error) {
+	defer t.busy()()
+	err = retryBusy(ctx, func() error {
+		data, err = t.readMemoryByAddress(ctx, address, length)
+		return err
+	})
+	return data, err
+}
+
+func (t *Client) readMemoryByAddress(ctx context.Context, address, length int) ([]byte, error) {
 	// Jump to read adress
-	t.c.SendFrame(REQ_MSG_ID, []byte{0x41, 0xA1, 0x08, 0x2C, 0xF0, 0x03, 0x00, byte(length)}, gocan.Outgoing)
-	frame := gocan.NewFrame(REQ_MSG_ID, []byte{0x00, 0xA1, byte((address >> 16) & 0xFF), byte((address >> 8) & 0xFF), byte(address & 0xFF), 0x00, 0x00, 0x00}, gocan.ResponseRequired)
-	f, err := t.c.SendAndPoll(ctx, frame, t.defaultTimeout*3, t.responseID)
+	t.c.SendFrame(t.reqID, []byte{0x41, 0xA1, 0x08, 0x2C, 0xF0, 0x03, 0x00, byte(length)}, gocan.Outgoing)
+	frame := gocan.NewFrame(t.reqID, []byte{0x00, 0xA1, byte((address >> 16) & 0xFF), byte((address >> 8) & 0xFF), byte(address & 0xFF), 0x00, 0x00, 0x00}, gocan.ResponseRequired)
+	f, err := t.c.SendAndPoll(ctx, frame, t.jumpTimeout, t.responseID)
 	if err != nil {
 		return nil, err
 	}
+	if f, err = t.waitPending(ctx, f); err != nil {
+		return nil, err
+	}
 	d := f.Data()
+	if err := checkFrame(d); err != nil {
+		return nil, fmt.Errorf("jump to address failed: %w", err)
+	}
 	t.Ack(d[0], gocan.Outgoing)
 
 	if d[3] != 0x6C || d[4] != 0xF0 {
 		if d[3] == 0x7F && d[4] == 0x2C {
-			return nil, fmt.Errorf("jump to address failed: %w", TranslateErrorCode(d[5]))
+			return nil, fmt.Errorf("jump to address failed: %w", newNegativeResponseError(d))
 		}
 		return nil, fmt.Errorf("failed to jump to 0x%X got response: %s", address, f.String())
 	}
@@ -600,17 +774,12 @@ func (t *Client) ReadMemoryByAddress(ctx context.Context, address, length int) (
 
 // Reset ECU
 func (t *Client) ResetECU(ctx context.Context) error {
-	frame := gocan.NewFrame(REQ_MSG_ID, []byte{0x40, 0xA1, 0x02, ECU_RESET, 0x01}, gocan.ResponseRequired)
-	f, err := t.c.SendAndPoll(ctx, frame, t.defaultTimeout, t.responseID)
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: ECU_RESET, Data: []byte{0x01}})
 	if err != nil {
-		return err
-	}
-	d := f.Data()
-	if d[3] == 0x7F {
-		return fmt.Errorf("failed to reset ECU: %s", TranslateErrorCode(d[5]))
+		return fmt.Errorf("failed to reset ECU: %w", err)
 	}
-	if d[3] != 0x51 || d[4] != 0x81 {
-		return fmt.Errorf("abnormal ecu reset response: %X", d[3:])
+	if len(reply.Data) < 1 || reply.Data[0] != 0x81 {
+		return fmt.Errorf("abnormal ecu reset response: %X", reply.Data)
 	}
 	return nil
 }
@@ -0,0 +1,68 @@
+package kwp2000
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	// MaxLocalIDPayload is the most data the ECU returns in one ReadDataByLocalIdentifier response
+	MaxLocalIDPayload = 0xF5
+	// dynamically defined local identifiers available to the tester
+	firstDynamicLocalID = 0xF0
+	lastDynamicLocalID  = 0xF7
+)
+
+// LocalIDGroup is a dynamically defined local identifier and the variables it returns, in order
+type LocalIDGroup struct {
+	ID        byte
+	Variables []*VarDefinition
+}
+
+// Length returns the size of the response data for the group
+func (g *LocalIDGroup) Length() int {
+	var n int
+	for _, v := range g.Variables {
+		n += int(v.Length)
+	}
+	return n
+}
+
+// Read decodes a ReadDataByLocalIdentifier response into the variables of the group
+func (g *LocalIDGroup) Read(data []byte) error {
+	if len(data) != g.Length() {
+		return fmt.Errorf("local id %02X: expected %d bytes, got %d", g.ID, g.Length(), len(data))
+	}
+	r := bytes.NewReader(data)
+	for _, v := range g.Variables {
+		if err := v.Read(r); err != nil {
+			return fmt.Errorf("local id %02X: %w", g.ID, err)
+		}
+	}
+	return nil
+}
+
+// SplitLocalIDs spreads variables in order over as many dynamically defined local identifiers
+// as needed to keep every response within MaxLocalIDPayload
+func SplitLocalIDs(vars []*VarDefinition) ([]*LocalIDGroup, error) {
+	var groups []*LocalIDGroup
+	var current *LocalIDGroup
+	size := 0
+	for _, v := range vars {
+		if int(v.Length) > MaxLocalIDPayload {
+			return nil, fmt.Errorf("SplitLocalIDs: %s is %d bytes, more than fits in one response", v.Name, v.Length)
+		}
+		if current == nil || size+int(v.Length) > MaxLocalIDPayload {
+			id := firstDynamicLocalID + len(groups)
+			if id > lastDynamicLocalID {
+				return nil, fmt.Errorf("SplitLocalIDs: variables need more than %d local ids", lastDynamicLocalID-firstDynamicLocalID+1)
+			}
+			current = &LocalIDGroup{ID: byte(id)}
+			groups = append(groups, current)
+			size = 0
+		}
+		current.Variables = append(current.Variables, v)
+		size += int(v.Length)
+	}
+	return groups, nil
+}
@@ -3,10 +3,13 @@ package kwp2000
 import (
 	"encoding/binary"
 	"fmt"
+	"go/constant"
 	"go/token"
 	"go/types"
 	"io"
 	"log"
+	"math"
+	"strconv"
 	"strings"
 
 	"fyne.io/fyne/v2"
@@ -56,6 +59,8 @@ type VarDefinition struct {
 	Value            int    `json:"value"`
 	Type             uint8  `json:"type"`
 	Length           uint16 `json:"length"`
+	Mask             uint16 `json:"mask,omitempty"`
+	ElementSize      uint16 `json:"elementsize,omitempty"` // array element size, guessed from the type if not set
 	Unit             string `json:"unit,omitempty"`
 	Correctionfactor string `json:"correctionfactor,omitempty"`
 	Visualization    string `json:"visualization,omitempty"`
@@ -67,6 +72,11 @@ func (v *VarDefinition) Set(data []byte) {
 	v.data = data
 }
 
+// Data returns the raw data last read for the variable
+func (v *VarDefinition) Data() []byte {
+	return v.data
+}
+
 func (v *VarDefinition) SetWidget(wb fyne.CanvasObject) {
 	v.Widget = wb
 }
@@ -113,6 +123,20 @@ func (v *VarDefinition) GetInt32() int32 {
 }
 
 func (v *VarDefinition) String() string {
+	switch {
+	case v.IsBitfield():
+		return v.bitfieldString(" ", false)
+	case v.IsString():
+		return fmt.Sprintf("%s=%s", v.Name, v.Text())
+	case v.IsRaw():
+		return fmt.Sprintf("%s=%s", v.Name, v.RawString())
+	case v.IsArray():
+		var out []string
+		for _, e := range v.Elements() {
+			out = append(out, e.String())
+		}
+		return strings.Join(out, " ")
+	}
 	if v.Correctionfactor != "" {
 		fs := token.NewFileSet()
 		tv, err := types.Eval(fs, nil, token.NoPos, fmt.Sprintf("%v*%s", v.Decode(), v.Correctionfactor))
@@ -125,6 +149,16 @@ func (v *VarDefinition) String() string {
 }
 
 func (v *VarDefinition) T7L() string {
+	switch {
+	case v.IsBitfield():
+		return v.bitfieldString("|", false)
+	case v.IsString():
+		return fmt.Sprintf("%s=%s", v.Name, v.Text())
+	case v.IsRaw():
+		return fmt.Sprintf("%s=%s", v.Name, v.RawString())
+	case v.IsArray():
+		return v.arrayString("|", false)
+	}
 	if v.Correctionfactor != "" {
 		fs := token.NewFileSet()
 		tv, err := types.Eval(fs, nil, token.NoPos, fmt.Sprintf("%v*%s", v.Decode(), v.Correctionfactor))
@@ -137,6 +171,16 @@ func (v *VarDefinition) T7L() string {
 }
 
 func (v *VarDefinition) Tuple() string {
+	switch {
+	case v.IsBitfield():
+		return v.bitfieldString(",", true)
+	case v.IsString():
+		return fmt.Sprintf("%d:%s", v.Value, v.Text())
+	case v.IsRaw():
+		return fmt.Sprintf("%d:%s", v.Value, v.RawString())
+	case v.IsArray():
+		return v.arrayString(",", true)
+	}
 	if v.Correctionfactor != "" {
 		fs := token.NewFileSet()
 		tv, err := types.Eval(fs, nil, token.NoPos, fmt.Sprintf("%v*%s", v.Decode(), v.Correctionfactor))
@@ -148,6 +192,89 @@ func (v *VarDefinition) Tuple() string {
 	return fmt.Sprintf("%d:%v", v.Value, v.Decode())
 }
 
+// StringValue returns the value with the correction factor applied
+func (v *VarDefinition) StringValue() string {
+	switch {
+	case v.IsBitfield():
+		var out []string
+		for _, b := range v.Bits() {
+			out = append(out, fmt.Sprint(boolValue(b)))
+		}
+		return strings.Join(out, " ")
+	case v.IsString():
+		return v.Text()
+	case v.IsRaw():
+		return v.RawString()
+	case v.IsArray():
+		var out []string
+		for _, e := range v.Elements() {
+			out = append(out, e.StringValue())
+		}
+		return strings.Join(out, " ")
+	}
+	if v.Correctionfactor != "" {
+		fs := token.NewFileSet()
+		tv, err := types.Eval(fs, nil, token.NoPos, fmt.Sprintf("%v*%s", v.Decode(), v.Correctionfactor))
+		if err != nil {
+			panic(err)
+		}
+		return tv.Value.String()
+	}
+	return fmt.Sprintf("%v", v.Decode())
+}
+
+// Encode converts a value with the correction factor applied back to the raw data the ECU stores
+func (v *VarDefinition) Encode(value float64) ([]byte, error) {
+	factor, offset, err := v.Linear()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", v.Name, err)
+	}
+	if factor == 0 {
+		return nil, fmt.Errorf("%s: correction factor %q can not be reversed", v.Name, v.Correctionfactor)
+	}
+	raw := math.Round((value - offset) / factor)
+
+	bits := int(v.Length) * 8
+	if v.Length != 1 && v.Length != 2 && v.Length != 4 {
+		return nil, fmt.Errorf("%s: unsupported length %d", v.Name, v.Length)
+	}
+	min, max := 0.0, math.Exp2(float64(bits))-1
+	if v.Type&SIGNED != 0 {
+		min, max = -math.Exp2(float64(bits-1)), math.Exp2(float64(bits-1))-1
+	}
+	if raw < min || raw > max {
+		return nil, fmt.Errorf("%s: %v is out of range", v.Name, value)
+	}
+
+	out := make([]byte, 4)
+	binary.BigEndian.PutUint32(out, uint32(int64(raw)))
+	return out[4-v.Length:], nil
+}
+
+// Linear returns the correction factor as phys = raw*factor+offset, 1 and 0 if there is none.
+// Factors that are not linear in raw are an error
+func (v *VarDefinition) Linear() (factor, offset float64, err error) {
+	if v.Correctionfactor == "" {
+		return 1, 0, nil
+	}
+	// two points give the line, the third checks the factor follows it
+	points := []float64{0, 1, 1000}
+	var p [3]float64
+	for i, x := range points {
+		fs := token.NewFileSet()
+		tv, err := types.Eval(fs, nil, token.NoPos, fmt.Sprintf("%s*%s", strconv.FormatFloat(x, 'f', 1, 64), v.Correctionfactor))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid correction factor %q: %w", v.Correctionfactor, err)
+		}
+		p[i], _ = constant.Float64Val(constant.ToFloat(tv.Value))
+	}
+	factor, offset = p[1]-p[0], p[0]
+	if want := points[2]*factor + offset; math.Abs(p[2]-want) > 1e-9*math.Max(1, math.Abs(want)) {
+		return 0, 0, fmt.Errorf("correction factor %q is not linear", v.Correctionfactor)
+	}
+	return factor, offset, nil
+}
+
 func (v *VarDefinition) Decode() interface{} {
 	switch {
 	case v.Length == 1:
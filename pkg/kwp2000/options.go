@@ -0,0 +1,76 @@
+package kwp2000
+
+import "time"
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithInitIDs sets the CAN ids the session is started on and answered from, defaults to INIT_MSG_ID and INIT_RESP_ID
+func WithInitIDs(msgID, respID uint32) Option {
+	return func(t *Client) {
+		t.initID = msgID
+		t.initRespID = respID
+	}
+}
+
+// WithRequestID sets the CAN id requests are sent on, defaults to REQ_MSG_ID
+func WithRequestID(id uint32) Option {
+	return func(t *Client) {
+		t.reqID = id
+	}
+}
+
+// WithChunkConfIDs sets the CAN ids the ECU confirms request chunks on and the tester confirms response chunks on,
+// defaults to REQ_CHUNK_CONF_ID and RESP_CHUNK_CONF_ID
+func WithChunkConfIDs(reqChunkConfID, respChunkConfID uint32) Option {
+	return func(t *Client) {
+		t.reqChunkConfID = reqChunkConfID
+		t.respChunkConfID = respChunkConfID
+	}
+}
+
+// WithTimeout sets how long to wait for a response, defaults to 250ms.
+// Timeouts not set explicitly are scaled from it
+func WithTimeout(d time.Duration) Option {
+	return func(t *Client) {
+		t.defaultTimeout = d
+	}
+}
+
+// WithReadDataTimeout sets the timeout for ReadDataByLocalIdentifier, which is kept short
+// so a lost frame only costs one sample while logging, defaults to 50ms
+func WithReadDataTimeout(d time.Duration) Option {
+	return func(t *Client) {
+		t.readDataTimeout = d
+	}
+}
+
+// WithChunkTimeout sets how long to wait for the next frame of a multi-frame response, defaults to 450ms
+func WithChunkTimeout(d time.Duration) Option {
+	return func(t *Client) {
+		t.chunkTimeout = d
+	}
+}
+
+// WithReadMemoryTimeout sets the timeouts for ReadMemoryByAddress, jump is the wait for the
+// jump to address to be accepted and recv the wait between data frames. Defaults to 3 and 4 times the timeout
+func WithReadMemoryTimeout(jump, recv time.Duration) Option {
+	return func(t *Client) {
+		t.jumpTimeout = jump
+		t.recvTimeout = recv
+	}
+}
+
+// WithResponsePendingTimeout sets how long to wait for the real response after the ECU answered response pending, defaults to 5s
+func WithResponsePendingTimeout(d time.Duration) Option {
+	return func(t *Client) {
+		t.pendingTimeout = d
+	}
+}
+
+// WithKeyRegistry sets the key algorithms tried for security access, defaults to DefaultKeyRegistry
+func WithKeyRegistry(r *KeyRegistry) Option {
+	return func(t *Client) {
+		t.keys = r
+	}
+}
@@ -0,0 +1,153 @@
+package kwp2000
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// KeyAlgorithm calculates the security access key for a seed
+type KeyAlgorithm interface {
+	Name() string
+	Key(seed uint16) uint16
+}
+
+type keyFunc struct {
+	name string
+	fn   func(seed uint16) uint16
+}
+
+func (k *keyFunc) Name() string           { return k.name }
+func (k *keyFunc) Key(seed uint16) uint16 { return k.fn(seed) }
+
+// NewKeyAlgorithm creates a named KeyAlgorithm from a function
+func NewKeyAlgorithm(name string, fn func(seed uint16) uint16) KeyAlgorithm {
+	return &keyFunc{name: name, fn: fn}
+}
+
+// t7Key returns the Trionic 7 key algorithm with the given xor and subtract constants
+func t7Key(name string, xor, sub uint16) KeyAlgorithm {
+	return NewKeyAlgorithm(name, func(seed uint16) uint16 {
+		return (seed<<2 ^ xor) - sub
+	})
+}
+
+// KeyRegistry holds the key algorithms tried for security access, in order, and remembers
+// which one the ECU accepted for each software version so it is tried first the next time
+type KeyRegistry struct {
+	mu         sync.Mutex
+	algorithms []KeyAlgorithm
+	known      map[string]string
+	onLearn    func(softwareVersion, algorithm string)
+}
+
+// NewKeyRegistry creates a registry trying algorithms in the given order
+func NewKeyRegistry(algorithms ...KeyAlgorithm) *KeyRegistry {
+	return &KeyRegistry{
+		algorithms: algorithms,
+		known:      make(map[string]string),
+	}
+}
+
+// DefaultKeyRegistry holds the known Trionic 7 variants and is used by clients unless WithKeyRegistry is given
+var DefaultKeyRegistry = NewKeyRegistry(
+	t7Key("T7", 0x8142, 0x2356),
+	t7Key("T7 alt 1", 0x4081, 0x1F6F),
+	t7Key("T7 alt 2", 0x3DC, 0x2356),
+	t7Key("T7 alt 3", 0x3D7, 0x2356),
+	t7Key("T7 alt 4", 0x409, 0x2356),
+)
+
+// Register adds an algorithm, it is tried after the ones already registered. An algorithm with the same name is replaced
+func (r *KeyRegistry) Register(alg KeyAlgorithm) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, a := range r.algorithms {
+		if a.Name() == alg.Name() {
+			r.algorithms[i] = alg
+			return
+		}
+	}
+	r.algorithms = append(r.algorithms, alg)
+}
+
+// Algorithms returns the algorithms in the order to try them for a software version,
+// the one remembered for it goes first
+func (r *KeyRegistry) Algorithms(softwareVersion string) []KeyAlgorithm {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]KeyAlgorithm, 0, len(r.algorithms))
+	name, ok := r.known[softwareVersion]
+	if ok && softwareVersion != "" {
+		for _, a := range r.algorithms {
+			if a.Name() == name {
+				out = append(out, a)
+			}
+		}
+	}
+	for _, a := range r.algorithms {
+		if !ok || softwareVersion == "" || a.Name() != name {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Learn remembers the algorithm the ECU with softwareVersion accepted
+func (r *KeyRegistry) Learn(softwareVersion, algorithm string) {
+	if softwareVersion == "" {
+		return
+	}
+	r.mu.Lock()
+	changed := r.known[softwareVersion] != algorithm
+	r.known[softwareVersion] = algorithm
+	onLearn := r.onLearn
+	r.mu.Unlock()
+	if changed && onLearn != nil {
+		onLearn(softwareVersion, algorithm)
+	}
+}
+
+// Known returns the algorithm names remembered by software version, to be stored between runs
+func (r *KeyRegistry) Known() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]string, len(r.known))
+	for k, v := range r.known {
+		out[k] = v
+	}
+	return out
+}
+
+// SetKnown restores algorithm names remembered by software version
+func (r *KeyRegistry) SetKnown(known map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, v := range known {
+		r.known[k] = v
+	}
+}
+
+// OnLearn sets a func called when an algorithm is remembered for a new software version or a different one is accepted
+func (r *KeyRegistry) OnLearn(fn func(softwareVersion, algorithm string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onLearn = fn
+}
+
+// SecurityAttempt is the outcome of trying one key algorithm
+type SecurityAttempt struct {
+	Algorithm string
+	Seed      uint16
+	Key       uint16
+	Duration  time.Duration
+	// Err is nil if the ECU granted access
+	Err error
+}
+
+func (a SecurityAttempt) String() string {
+	if a.Err == nil {
+		return fmt.Sprintf("%s: seed %04X key %04X granted in %s", a.Algorithm, a.Seed, a.Key, a.Duration.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s: seed %04X key %04X failed in %s: %v", a.Algorithm, a.Seed, a.Key, a.Duration.Round(time.Millisecond), a.Err)
+}
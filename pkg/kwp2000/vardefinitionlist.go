@@ -67,6 +67,11 @@ func (v *VarDefinitionList) SetCorrectionfactor(pos int, correctionfactor string
 	//v.updated()
 }
 
+func (v *VarDefinitionList) SetMask(pos int, mask uint16) {
+	v.data[pos].Mask = mask
+	//v.updated()
+}
+
 func (v *VarDefinitionList) Delete(pos int) {
 	v.data = append(v.data[:pos], v.data[pos+1:]...)
 	//v.updated()
@@ -78,6 +83,7 @@ func (v *VarDefinitionList) UpdatePos(i int, sym *VarDefinition) {
 	v.data[i].Value = sym.Value
 	v.data[i].Type = sym.Type
 	v.data[i].Length = sym.Length
+	v.data[i].Mask = sym.Mask
 	v.data[i].Unit = sym.Unit
 	v.data[i].Correctionfactor = sym.Correctionfactor
 	v.data[i].Unit = symbol.GetUnit(sym.Name)
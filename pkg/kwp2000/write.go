@@ -0,0 +1,59 @@
+package kwp2000
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// WriteMemoryByAddress writes data to RAM, requires security access
+func (t *Client) WriteMemoryByAddress(ctx context.Context, address int, data []byte) error {
+	if err := t.writeMemory(ctx, []byte{byte(address >> 16), byte(address >> 8), byte(address)}, data); err != nil {
+		return fmt.Errorf("WriteMemoryByAddress: %w", err)
+	}
+	return nil
+}
+
+func (t *Client) writeMemory(ctx context.Context, address, data []byte) error {
+	if len(data) == 0 || len(data) > 0xF0 {
+		return fmt.Errorf("invalid length %d", len(data))
+	}
+	payload := append(append(address, byte(len(data))), data...)
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: WRITE_MEMORY_BY_ADDRESS, Data: payload})
+	if err != nil {
+		return err
+	}
+	if len(reply.Data) < 3 || reply.Data[0] != address[0] || reply.Data[1] != address[1] || reply.Data[2] != address[2] {
+		return fmt.Errorf("unexpected response %X", reply.Data)
+	}
+	return nil
+}
+
+// WriteDataByLocalIdentifier writes the value of a local identifier
+func (t *Client) WriteDataByLocalIdentifier(ctx context.Context, id byte, data []byte) error {
+	reply, err := t.SendRequest(ctx, &KWPRequest{ServiceID: WRITE_DATA_BY_LOCAL_IDENTIFIER, Data: append([]byte{id}, data...)})
+	if err != nil {
+		return fmt.Errorf("WriteDataByLocalIdentifier: %w", err)
+	}
+	if len(reply.Data) < 1 || reply.Data[0] != id {
+		return fmt.Errorf("WriteDataByLocalIdentifier: unexpected response %X", reply.Data)
+	}
+	return nil
+}
+
+// WriteVar writes raw data to a variable using the method it is defined with, variables defined by
+// symbol number have to be resolved to their address first as there is no write by symbol number
+func (t *Client) WriteVar(ctx context.Context, v *VarDefinition, data []byte) error {
+	if len(data) != int(v.Length) {
+		return fmt.Errorf("WriteVar: %s is %d bytes, got %d", v.Name, v.Length, len(data))
+	}
+	switch v.Method {
+	case VAR_METHOD_ADDRESS:
+		return t.WriteMemoryByAddress(ctx, v.Value, data)
+	case VAR_METHOD_LOCID:
+		return t.WriteDataByLocalIdentifier(ctx, byte(v.Value), data)
+	case VAR_METHOD_SYMBOL:
+		return fmt.Errorf("WriteVar: %s is defined by symbol number, resolve its address first", v.Name)
+	}
+	return errors.New("WriteVar: unknown method")
+}
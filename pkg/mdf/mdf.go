@@ -0,0 +1,310 @@
+// Package mdf writes ASAM MDF 4.10 measurement files with a single channel group of fixed size records
+package mdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// DataType is the encoding of a channel value in a record
+type DataType uint8
+
+const (
+	UnsignedLE DataType = 0
+	UnsignedBE DataType = 1
+	SignedLE   DataType = 2
+	SignedBE   DataType = 3
+	FloatLE    DataType = 4
+	FloatBE    DataType = 5
+	// String is ISO-8859-1 text padded with NUL
+	String DataType = 6
+	// ByteArray is raw bytes shown as they are
+	ByteArray DataType = 10
+)
+
+const (
+	// FlushSize is the number of buffered record bytes that triggers writing a block
+	FlushSize = 64 << 10
+	// FlushInterval is the longest time records are buffered
+	FlushInterval = time.Second
+
+	version = 410
+
+	// unfinalized flags: cycle counters and the length of the last DT block need updating,
+	// bit 1 is for SR blocks which aren't written
+	unfinCycleCount = 1 << 0
+	unfinDTLength   = 1 << 2
+)
+
+// ToolVersion is stored in the file history with the tool name
+var ToolVersion = ""
+
+// Channel describes one value in a record, records hold the channels in order after the time
+type Channel struct {
+	Name     string
+	Unit     string
+	DataType DataType
+	// Size in bytes
+	Size int
+	// Factor and Offset convert the raw value as raw*Factor+Offset, no conversion is stored if Factor is 0
+	Factor float64
+	Offset float64
+}
+
+// File is where the measurement is written, blocks are updated in place as records are appended
+type File interface {
+	io.WriterAt
+	io.Closer
+}
+
+// Writer appends records to an MDF file. Every flush leaves a valid file, the header is only
+// marked finalized on Close so readers recover the records of a session that stopped unexpectedly
+type Writer struct {
+	f          File
+	start      time.Time
+	recordSize int
+
+	cgOffset int64
+	dtOffset int64
+	dataEnd  int64
+	cycles   uint64
+
+	buf       bytes.Buffer
+	lastFlush time.Time
+}
+
+// Create writes the header of a measurement starting at start. Properties such as the
+// ECU identification are stored as name/value pairs in the file comment
+func Create(f File, start time.Time, channels []Channel, properties [][2]string) (*Writer, error) {
+	w := &Writer{
+		f:          f,
+		start:      start,
+		recordSize: 8,
+		lastFlush:  start,
+	}
+	for _, c := range channels {
+		if c.Size < 1 {
+			return nil, fmt.Errorf("mdf: channel %s: invalid size %d", c.Name, c.Size)
+		}
+		w.recordSize += c.Size
+	}
+
+	b := &builder{}
+	b.buf.Write(make([]byte, 64)) // id block
+	hdOffset := b.reserve(104)
+
+	comment := b.md(hdComment(properties))
+	// a file has at least one history entry telling which tool created it
+	fh := make([]byte, 16)
+	binary.LittleEndian.PutUint64(fh, uint64(start.UnixNano()))
+	history := b.block("FH", []uint64{0, b.md(fhComment())}, fh)
+
+	// channels are linked to the next one so they are written back to front
+	var next uint64
+	byteOffset := uint32(w.recordSize)
+	for i := len(channels) - 1; i >= 0; i-- {
+		c := channels[i]
+		byteOffset -= uint32(c.Size)
+		var cc uint64
+		if c.Factor != 0 {
+			cc = b.block("CC", []uint64{0, 0, 0, 0}, ccLinear(c.Factor, c.Offset))
+		}
+		var unit uint64
+		if c.Unit != "" {
+			unit = b.tx(c.Unit)
+		}
+		next = b.block("CN", []uint64{next, 0, b.tx(c.Name), 0, cc, 0, unit, 0}, cnData(0, 0, c.DataType, byteOffset, uint32(c.Size*8)))
+	}
+	// the master channel holds the seconds since start
+	timeCN := b.block("CN", []uint64{next, 0, b.tx("time"), 0, 0, 0, b.tx("s"), 0}, cnData(2, 1, FloatLE, 0, 64))
+
+	cgData := make([]byte, 32)
+	binary.LittleEndian.PutUint32(cgData[24:], uint32(w.recordSize))
+	cg := b.block("CG", []uint64{0, timeCN, b.tx("t7logger"), 0, 0, 0}, cgData)
+	w.cgOffset = int64(cg)
+
+	// the DT block follows the DG block and grows with every flush
+	dg := b.align()
+	w.dtOffset = int64(dg) + 64
+	b.block("DG", []uint64{0, cg, uint64(w.dtOffset), 0}, make([]byte, 8))
+	b.block("DT", nil, nil)
+	w.dataEnd = int64(b.buf.Len())
+
+	hd := make([]byte, 32)
+	binary.LittleEndian.PutUint64(hd, uint64(start.UnixNano()))
+	b.fill(hdOffset, "HD", []uint64{dg, history, 0, 0, 0, comment}, hd)
+
+	data := b.buf.Bytes()
+	copy(data, idBlock(true))
+	if _, err := f.WriteAt(data, 0); err != nil {
+		return nil, fmt.Errorf("mdf: %w", err)
+	}
+	return w, nil
+}
+
+// Write buffers a record sampled at ts, record holds the channel values in order
+func (w *Writer) Write(ts time.Time, record []byte) error {
+	if len(record) != w.recordSize-8 {
+		return fmt.Errorf("mdf: record is %d bytes, expected %d", len(record), w.recordSize-8)
+	}
+	var t [8]byte
+	binary.LittleEndian.PutUint64(t[:], math.Float64bits(ts.Sub(w.start).Seconds()))
+	w.buf.Write(t[:])
+	w.buf.Write(record)
+	w.cycles++
+	if w.buf.Len() >= FlushSize || ts.Sub(w.lastFlush) >= FlushInterval {
+		w.lastFlush = ts
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush appends the buffered records to the DT block and updates its length and the record count
+func (w *Writer) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	if _, err := w.f.WriteAt(w.buf.Bytes(), w.dataEnd); err != nil {
+		return fmt.Errorf("mdf: %w", err)
+	}
+	w.dataEnd += int64(w.buf.Len())
+	w.buf.Reset()
+
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(w.dataEnd-w.dtOffset))
+	if _, err := w.f.WriteAt(b[:], w.dtOffset+8); err != nil {
+		return fmt.Errorf("mdf: %w", err)
+	}
+	// cg_cycle_count follows the 6 links and the record id
+	binary.LittleEndian.PutUint64(b[:], w.cycles)
+	if _, err := w.f.WriteAt(b[:], w.cgOffset+24+6*8+8); err != nil {
+		return fmt.Errorf("mdf: %w", err)
+	}
+	return nil
+}
+
+// Close writes the remaining records, marks the file finalized and closes it
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		w.f.Close()
+		return err
+	}
+	if _, err := w.f.WriteAt(idBlock(false), 0); err != nil {
+		w.f.Close()
+		return fmt.Errorf("mdf: %w", err)
+	}
+	return w.f.Close()
+}
+
+func idBlock(unfinalized bool) []byte {
+	id := make([]byte, 64)
+	copy(id, "MDF     ")
+	if unfinalized {
+		copy(id, "UnFinMF ")
+		binary.LittleEndian.PutUint16(id[60:], unfinCycleCount|unfinDTLength)
+	}
+	copy(id[8:], "4.10    ")
+	copy(id[16:], "t7logger")
+	binary.LittleEndian.PutUint16(id[28:], version)
+	return id
+}
+
+func hdComment(properties [][2]string) string {
+	var out bytes.Buffer
+	out.WriteString("<HDcomment><TX>Trionic logger</TX>")
+	if len(properties) > 0 {
+		out.WriteString("<common_properties>")
+		for _, p := range properties {
+			out.WriteString(`<e name="`)
+			xml.EscapeText(&out, []byte(p[0]))
+			out.WriteString(`">`)
+			xml.EscapeText(&out, []byte(p[1]))
+			out.WriteString("</e>")
+		}
+		out.WriteString("</common_properties>")
+	}
+	out.WriteString("</HDcomment>")
+	return out.String()
+}
+
+func fhComment() string {
+	var out bytes.Buffer
+	out.WriteString("<FHcomment><TX>created</TX><tool_id>t7logger</tool_id><tool_vendor>roffe</tool_vendor><tool_version>")
+	xml.EscapeText(&out, []byte(ToolVersion))
+	out.WriteString("</tool_version></FHcomment>")
+	return out.String()
+}
+
+func cnData(cnType, syncType uint8, dataType DataType, byteOffset, bitCount uint32) []byte {
+	d := make([]byte, 72)
+	d[0] = cnType
+	d[1] = syncType
+	d[2] = uint8(dataType)
+	binary.LittleEndian.PutUint32(d[4:], byteOffset)
+	binary.LittleEndian.PutUint32(d[8:], bitCount)
+	return d
+}
+
+func ccLinear(factor, offset float64) []byte {
+	d := make([]byte, 40)
+	d[0] = 1 // linear
+	binary.LittleEndian.PutUint16(d[6:], 2)
+	binary.LittleEndian.PutUint64(d[24:], math.Float64bits(offset))
+	binary.LittleEndian.PutUint64(d[32:], math.Float64bits(factor))
+	return d
+}
+
+// builder lays out blocks at 8 byte aligned offsets
+type builder struct {
+	buf bytes.Buffer
+}
+
+func (b *builder) align() uint64 {
+	for b.buf.Len()%8 != 0 {
+		b.buf.WriteByte(0)
+	}
+	return uint64(b.buf.Len())
+}
+
+func (b *builder) reserve(size int) uint64 {
+	offset := b.align()
+	b.buf.Write(make([]byte, size))
+	return offset
+}
+
+func (b *builder) block(id string, links []uint64, data []byte) uint64 {
+	offset := b.align()
+	b.buf.Write(encodeBlock(id, links, data))
+	return offset
+}
+
+func (b *builder) fill(offset uint64, id string, links []uint64, data []byte) {
+	copy(b.buf.Bytes()[offset:], encodeBlock(id, links, data))
+}
+
+func (b *builder) tx(text string) uint64 {
+	return b.block("TX", nil, append([]byte(text), 0))
+}
+
+func (b *builder) md(text string) uint64 {
+	return b.block("MD", nil, append([]byte(text), 0))
+}
+
+func encodeBlock(id string, links []uint64, data []byte) []byte {
+	size := 24 + 8*len(links) + len(data)
+	size = (size + 7) &^ 7
+	out := make([]byte, size)
+	copy(out, "##"+id)
+	binary.LittleEndian.PutUint64(out[8:], uint64(size))
+	binary.LittleEndian.PutUint64(out[16:], uint64(len(links)))
+	for i, l := range links {
+		binary.LittleEndian.PutUint64(out[24+8*i:], l)
+	}
+	copy(out[24+8*len(links):], data)
+	return out
+}
@@ -0,0 +1,199 @@
+package mdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memFile is a File in memory
+type memFile struct {
+	b      []byte
+	closed bool
+}
+
+func (m *memFile) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(m.b) {
+		m.b = append(m.b, make([]byte, end-len(m.b))...)
+	}
+	return copy(m.b[off:], p), nil
+}
+
+func (m *memFile) Close() error {
+	m.closed = true
+	return nil
+}
+
+type block struct {
+	id    string
+	links []uint64
+	data  []byte
+}
+
+func readBlock(t *testing.T, b []byte, offset uint64) block {
+	t.Helper()
+	if offset == 0 || offset%8 != 0 || offset+24 > uint64(len(b)) {
+		t.Fatalf("invalid block offset 0x%X", offset)
+	}
+	h := b[offset:]
+	length := binary.LittleEndian.Uint64(h[8:])
+	count := binary.LittleEndian.Uint64(h[16:])
+	if length < 24+8*count || offset+length > uint64(len(b)) {
+		t.Fatalf("block %q at 0x%X: invalid length %d", h[:4], offset, length)
+	}
+	blk := block{id: string(h[:4]), data: h[24+8*count : length]}
+	for i := uint64(0); i < count; i++ {
+		blk.links = append(blk.links, binary.LittleEndian.Uint64(h[24+8*i:]))
+	}
+	return blk
+}
+
+func readText(t *testing.T, b []byte, offset uint64, id string) string {
+	t.Helper()
+	blk := readBlock(t, b, offset)
+	if blk.id != id {
+		t.Fatalf("block at 0x%X is %q, want %q", offset, blk.id, id)
+	}
+	return string(bytes.TrimRight(blk.data, "\x00"))
+}
+
+var testChannels = []Channel{
+	{Name: "rpm", Unit: "rpm", DataType: UnsignedBE, Size: 2},
+	{Name: "boost", Unit: "bar", DataType: SignedBE, Size: 2, Factor: 0.01, Offset: -1},
+}
+
+// checkFile parses the file back and checks it holds the records written by TestWriter
+func checkFile(t *testing.T, b []byte, finalized bool, records [][]byte) {
+	t.Helper()
+	id := string(b[:8])
+	flags := binary.LittleEndian.Uint16(b[60:])
+	if finalized && (id != "MDF     " || flags != 0) {
+		t.Errorf("finalized file has id %q and unfinalized flags %b", id, flags)
+	}
+	if !finalized && (id != "UnFinMF " || flags != unfinCycleCount|unfinDTLength) {
+		t.Errorf("unfinalized file has id %q and flags %b", id, flags)
+	}
+	if v := binary.LittleEndian.Uint16(b[28:]); v != 410 {
+		t.Errorf("version %d", v)
+	}
+
+	hd := readBlock(t, b, 64)
+	if hd.id != "##HD" || len(hd.links) != 6 {
+		t.Fatalf("header block is %q with %d links", hd.id, len(hd.links))
+	}
+	fh := readBlock(t, b, hd.links[1])
+	if fh.id != "##FH" || binary.LittleEndian.Uint64(fh.data) == 0 {
+		t.Errorf("file history is %q with time %d", fh.id, binary.LittleEndian.Uint64(fh.data))
+	}
+	if c := readText(t, b, fh.links[1], "##MD"); !strings.Contains(c, "<tool_id>t7logger</tool_id>") {
+		t.Errorf("file history comment %q", c)
+	}
+	if c := readText(t, b, hd.links[5], "##MD"); !strings.Contains(c, `<e name="VIN">YS3</e>`) {
+		t.Errorf("header comment %q", c)
+	}
+
+	dg := readBlock(t, b, hd.links[0])
+	if dg.id != "##DG" {
+		t.Fatalf("data group is %q", dg.id)
+	}
+	cg := readBlock(t, b, dg.links[1])
+	if cg.id != "##CG" {
+		t.Fatalf("channel group is %q", cg.id)
+	}
+	recordSize := 8 + 2 + 2
+	if n := binary.LittleEndian.Uint64(cg.data[8:]); n != uint64(len(records)) {
+		t.Errorf("cg_cycle_count %d, want %d", n, len(records))
+	}
+	if n := binary.LittleEndian.Uint32(cg.data[24:]); n != uint32(recordSize) {
+		t.Errorf("record size %d, want %d", n, recordSize)
+	}
+
+	var names []string
+	for next := cg.links[1]; next != 0; {
+		cn := readBlock(t, b, next)
+		if cn.id != "##CN" {
+			t.Fatalf("channel is %q", cn.id)
+		}
+		name := readText(t, b, cn.links[2], "##TX")
+		names = append(names, name)
+		if name == "boost" {
+			cc := readBlock(t, b, cn.links[4])
+			if cc.id != "##CC" || cc.data[0] != 1 || binary.LittleEndian.Uint16(cc.data[6:]) != 2 {
+				t.Fatalf("boost conversion is %q type %d", cc.id, cc.data[0])
+			}
+			offset := math.Float64frombits(binary.LittleEndian.Uint64(cc.data[24:]))
+			factor := math.Float64frombits(binary.LittleEndian.Uint64(cc.data[32:]))
+			if offset != -1 || factor != 0.01 {
+				t.Errorf("boost converts with factor %v offset %v", factor, offset)
+			}
+			if bo := binary.LittleEndian.Uint32(cn.data[4:]); bo != 10 {
+				t.Errorf("boost at byte %d, want 10", bo)
+			}
+		} else if cn.links[4] != 0 {
+			t.Errorf("%s has a conversion", name)
+		}
+		next = cn.links[0]
+	}
+	if strings.Join(names, ",") != "time,rpm,boost" {
+		t.Errorf("channels %v", names)
+	}
+
+	dt := readBlock(t, b, dg.links[2])
+	if dt.id != "##DT" {
+		t.Fatalf("data block is %q", dt.id)
+	}
+	if len(dt.data) != len(records)*recordSize {
+		t.Fatalf("data block holds %d bytes, want %d", len(dt.data), len(records)*recordSize)
+	}
+	for i, r := range records {
+		rec := dt.data[i*recordSize : (i+1)*recordSize]
+		if ts := math.Float64frombits(binary.LittleEndian.Uint64(rec)); math.Abs(ts-float64(i)*0.1) > 1e-9 {
+			t.Errorf("record %d at %v s", i, ts)
+		}
+		if !bytes.Equal(rec[8:], r) {
+			t.Errorf("record %d is % X, want % X", i, rec[8:], r)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	f := &memFile{}
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	w, err := Create(f, start, testChannels, [][2]string{{"VIN", "YS3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, f.b, false, nil)
+
+	var records [][]byte
+	for i := 0; i < 3; i++ {
+		r := []byte{0x03, byte(i), 0xFF, byte(0xF0 + i)}
+		records = append(records, r)
+		if err := w.Write(start.Add(time.Duration(i)*100*time.Millisecond), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write(start, []byte{1}); err == nil {
+		t.Error("short record was accepted")
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, f.b, false, records)
+
+	r := []byte{0x04, 0x00, 0x00, 0x10}
+	records = append(records, r)
+	if err := w.Write(start.Add(300*time.Millisecond), r); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !f.closed {
+		t.Error("file not closed")
+	}
+	checkFile(t, f.b, true, records)
+}
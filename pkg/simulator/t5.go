@@ -0,0 +1,180 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/symbol"
+	"github.com/roffe/t7logger/pkg/t5"
+)
+
+const t5RamSize = 0x10000
+
+// T5 is a simulated Trionic 5 ECU that implements gocan.Adapter.
+//
+// Commands are read from 0x005 and answered on 0x00C, the symbol table is served
+// for the S command and RAM is read with read memory requests
+type T5 struct {
+	cfg T5Config
+
+	send, recv chan gocan.CANFrame
+	close      chan struct{}
+	closeOnce  sync.Once
+
+	mu  sync.Mutex
+	ram []byte
+
+	command []byte
+	output  []byte
+}
+
+type T5Config struct {
+	// Symbols are served in the symbol table, they are placed in RAM at their address
+	Symbols []*symbol.Symbol
+	// Latency is how long the ECU waits before answering
+	Latency time.Duration
+	// OnMessage gets called with every frame sent and received when set
+	OnMessage func(string)
+}
+
+func NewT5(cfg T5Config) *T5 {
+	if cfg.Latency == 0 {
+		cfg.Latency = time.Millisecond
+	}
+	return &T5{
+		cfg:   cfg,
+		send:  make(chan gocan.CANFrame, 10),
+		recv:  make(chan gocan.CANFrame, 20),
+		close: make(chan struct{}),
+		ram:   make([]byte, t5RamSize),
+	}
+}
+
+func (s *T5) Name() string {
+	return "T5 Simulator"
+}
+
+func (s *T5) Init(ctx context.Context) error {
+	go s.run(ctx)
+	return nil
+}
+
+func (s *T5) SetFilter(filters []uint32) error {
+	return nil
+}
+
+func (s *T5) Recv() <-chan gocan.CANFrame {
+	return s.recv
+}
+
+func (s *T5) Send() chan<- gocan.CANFrame {
+	return s.send
+}
+
+func (s *T5) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.close)
+	})
+	return nil
+}
+
+// SetSymbol writes the value of a symbol to the simulated RAM
+func (s *T5) SetSymbol(name string, data []byte) error {
+	for _, sym := range s.cfg.Symbols {
+		if sym.Name == name {
+			if len(data) > int(sym.Length) || int(sym.Address)+len(data) > t5RamSize {
+				return fmt.Errorf("%s is %d bytes at 0x%X, got %d", name, sym.Length, sym.Address, len(data))
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			copy(s.ram[sym.Address:], data)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown symbol %s", name)
+}
+
+func (s *T5) log(str string) {
+	if s.cfg.OnMessage != nil {
+		s.cfg.OnMessage(str)
+	}
+}
+
+func (s *T5) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.close:
+			return
+		case f := <-s.send:
+			s.log(f.String())
+			s.handle(f)
+		}
+	}
+}
+
+func (s *T5) reply(data []byte) {
+	time.Sleep(s.cfg.Latency)
+	f := gocan.NewFrame(t5.RESP_MSG_ID, padFrame(data), gocan.Incoming)
+	s.log(f.String())
+	select {
+	case s.recv <- f:
+	case <-s.close:
+	}
+}
+
+func (s *T5) handle(f gocan.CANFrame) {
+	d := f.Data()
+	if f.Identifier() != t5.REQ_MSG_ID || len(d) != 8 {
+		return
+	}
+	switch d[0] {
+	case t5.SEND_COMMAND_BYTE:
+		s.command = append(s.command, d[1])
+		if d[1] == '\r' {
+			s.runCommand(strings.TrimSuffix(string(s.command), "\r"))
+			s.command = nil
+		}
+		s.reply([]byte{t5.READ_RESPONSE, 0x00})
+	case t5.READ_RESPONSE:
+		if len(s.output) == 0 {
+			s.reply([]byte{t5.READ_RESPONSE, 0x01})
+			return
+		}
+		b := s.output[0]
+		s.output = s.output[1:]
+		s.reply([]byte{t5.READ_RESPONSE, 0x00, b})
+	case t5.READ_MEMORY:
+		end := uint32(d[1])<<24 | uint32(d[2])<<16 | uint32(d[3])<<8 | uint32(d[4])
+		if end < t5.MaxReadMemory-1 || end >= t5RamSize {
+			s.reply([]byte{t5.READ_MEMORY, 0x01})
+			return
+		}
+		resp := []byte{t5.READ_MEMORY, 0x00}
+		s.mu.Lock()
+		for i := 0; i < t5.MaxReadMemory; i++ {
+			resp = append(resp, s.ram[end-uint32(i)])
+		}
+		s.mu.Unlock()
+		s.reply(resp)
+	}
+}
+
+func (s *T5) runCommand(cmd string) {
+	switch cmd {
+	case "S":
+		var sb strings.Builder
+		for _, sym := range s.cfg.Symbols {
+			fmt.Fprintf(&sb, "%04X%04X%s\r\n", sym.Address, sym.Length, sym.Name)
+		}
+		sb.WriteString("END\r\n")
+		s.output = []byte(sb.String())
+	default:
+		s.output = nil
+	}
+}
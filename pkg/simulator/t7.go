@@ -0,0 +1,673 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+	"github.com/roffe/t7logger/pkg/symbol"
+)
+
+const (
+	sramStart = 0xF00000
+	sramSize  = 0x10000
+	// max data bytes per TransferData block when sending the symbol table
+	transferBlockSize = 0xF0
+)
+
+// T7 is a simulated Trionic 7 ECU that implements gocan.Adapter.
+//
+// It answers on the same CAN ids as a real ECU, requests are read from 0x220/0x222 & 0x240/0x242,
+// responses are sent on 0x238 & 0x258, acks from the tester are expected on 0x266
+// and multi-frame requests are acked on 0x270
+type T7 struct {
+	cfg T7Config
+
+	send, recv chan gocan.CANFrame
+	close      chan struct{}
+	closeOnce  sync.Once
+
+	mu   sync.Mutex
+	sram []byte
+
+	symbols   []*symbol.Symbol
+	symTable  []byte
+	symTblPos int
+
+	session       bool
+	dropSession   bool
+	lastRequest   time.Time
+	securityGrant bool
+	seed          uint16
+
+	request []byte
+	pending []gocan.CANFrame // response frames waiting for ack
+	busy    int
+	dynamic map[byte][]dynamicEntry // dynamically defined local ids
+
+	dtcs         []kwp2000.DTC
+	freezeFrames [][]byte
+
+	outputs map[byte]byte // outputs controlled by the tester and their state
+}
+
+type T7Config struct {
+	// Bin is the 512 KB flash image served by ReadMemoryByAddress and used for the symbol table
+	Bin []byte
+	// KeyMethod selects which of the known seed/key variants the ECU accepts
+	KeyMethod int
+	// Latency is how long the ECU waits before sending each frame
+	Latency time.Duration
+	// SessionTimeout drops the session when no request was received for this long, disabled when 0
+	SessionTimeout time.Duration
+	// ResponsePending makes routines and data reads answer response pending and respond after this long
+	ResponsePending time.Duration
+	// BusyResponses is the number of requests answered with busy repeat request before serving any
+	BusyResponses int
+	// OnMessage gets called with every frame sent and received when set
+	OnMessage func(string)
+	// Identification is served by ReadECUIdentification, defaults are used for empty fields
+	Identification kwp2000.ECUIdentification
+	// Outputs are the local ids InputOutputControlByLocalIdentifier accepts, any id is accepted if empty
+	Outputs []byte
+}
+
+type dynamicEntry struct {
+	address uint32
+	length  int
+}
+
+// NewT7 creates a simulated ECU from a binary, symbols are only available if the binary has a packed symbol table
+func NewT7(cfg T7Config) (*T7, error) {
+	if len(cfg.Bin) == 0 {
+		return nil, errors.New("no binary given")
+	}
+	if cfg.Latency == 0 {
+		cfg.Latency = time.Millisecond
+	}
+	for _, f := range []struct {
+		dst *string
+		def string
+	}{
+		{&cfg.Identification.VIN, "YS3FH41U571000001"},
+		{&cfg.Identification.SoftwareVersion, "EU0AF01C.55P"},
+		{&cfg.Identification.SoftwarePartNumber, "5382498"},
+		{&cfg.Identification.HardwareNumber, "55562809"},
+		{&cfg.Identification.EngineType, "B235R EU0AF01C"},
+		{&cfg.Identification.ImmobilizerCode, "0000000000000"},
+	} {
+		if *f.dst == "" {
+			*f.dst = f.def
+		}
+	}
+	sim := &T7{
+		cfg:   cfg,
+		send:  make(chan gocan.CANFrame, 10),
+		recv:  make(chan gocan.CANFrame, 20),
+		close: make(chan struct{}),
+		sram:  make([]byte, sramSize),
+
+		outputs: make(map[byte]byte),
+	}
+	if err := sim.loadSymbols(); err != nil {
+		sim.log(fmt.Sprintf("no symbols loaded: %v", err))
+	}
+	return sim, nil
+}
+
+func (s *T7) loadSymbols() (err error) {
+	// the symbol helpers panic on binaries without the expected tables
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid binary: %v", r)
+		}
+	}()
+	r := bytes.NewReader(s.cfg.Bin)
+	offset, length, err := symbol.CompressedSymbolTableLocation(r)
+	if err != nil {
+		return err
+	}
+	symbols, err := symbol.BinaryPacked(func(string) {}, r)
+	if err != nil {
+		return err
+	}
+	if len(symbols) == 0 {
+		return errors.New("binary has no symbols")
+	}
+	s.symbols = symbols
+
+	// the ECU reports the location of the compressed name table in the first entry
+	buff := bytes.NewBuffer(nil)
+	for i, sym := range symbols {
+		addr, l := sym.Address, sym.Length
+		if i == 0 {
+			addr, l = uint32(offset), uint16(length)
+		}
+		binary.Write(buff, binary.BigEndian, addr)
+		binary.Write(buff, binary.BigEndian, l)
+		buff.WriteByte(sym.Type)
+	}
+	s.symTable = buff.Bytes()
+	return nil
+}
+
+func (s *T7) Name() string {
+	return "T7 Simulator"
+}
+
+func (s *T7) Init(ctx context.Context) error {
+	go s.run(ctx)
+	return nil
+}
+
+func (s *T7) SetFilter(filters []uint32) error {
+	return nil
+}
+
+func (s *T7) Recv() <-chan gocan.CANFrame {
+	return s.recv
+}
+
+func (s *T7) Send() chan<- gocan.CANFrame {
+	return s.send
+}
+
+func (s *T7) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.close)
+	})
+	return nil
+}
+
+// Symbols returns the symbols served by the simulator
+func (s *T7) Symbols() []*symbol.Symbol {
+	return s.symbols
+}
+
+// SetMemory writes data to the simulated SRAM
+func (s *T7) SetMemory(address uint32, data []byte) error {
+	if address < sramStart || int(address-sramStart)+len(data) > sramSize {
+		return fmt.Errorf("address 0x%X outside of SRAM", address)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copy(s.sram[address-sramStart:], data)
+	return nil
+}
+
+// SetSymbol writes the value of a symbol to the simulated SRAM
+func (s *T7) SetSymbol(name string, data []byte) error {
+	for _, sym := range s.symbols {
+		if sym.Name == name {
+			if len(data) > int(sym.Length) {
+				return fmt.Errorf("%s is %d bytes, got %d", name, sym.Length, len(data))
+			}
+			return s.SetMemory(sym.Address, data)
+		}
+	}
+	return fmt.Errorf("unknown symbol %s", name)
+}
+
+// DropSession ends the session as if the ECU had lost power, requests are ignored until a new session is started
+func (s *T7) DropSession() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropSession = true
+}
+
+// Output returns the state of an output and if it is controlled by the tester
+func (s *T7) Output(id byte) (byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.outputs[id]
+	return state, ok
+}
+
+func (s *T7) releaseOutputs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outputs = make(map[byte]byte)
+}
+
+// SetDTCs sets the trouble codes stored in the ECU, each gets a freeze frame of the current SRAM values.
+// The frames follow kwp2000.FreezeFrameSymbols as the real layout is unknown, they end at the first
+// symbol the binary does not have
+func (s *T7) SetDTCs(dtcs ...kwp2000.DTC) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dtcs = dtcs
+	s.freezeFrames = s.freezeFrames[:0]
+	for _, dtc := range dtcs {
+		frame := []byte{byte(dtc.Code >> 8), byte(dtc.Code)}
+	layout:
+		for _, name := range kwp2000.FreezeFrameSymbols {
+			for _, sym := range s.symbols {
+				if sym.Name == name && sym.Address >= sramStart && int(sym.Address-sramStart)+int(sym.Length) <= sramSize {
+					frame = append(frame, s.sram[sym.Address-sramStart:int(sym.Address-sramStart)+int(sym.Length)]...)
+					continue layout
+				}
+			}
+			break
+		}
+		s.freezeFrames = append(s.freezeFrames, frame)
+	}
+}
+
+func (s *T7) readMemory(address uint32, length int) ([]byte, error) {
+	out := make([]byte, length)
+	switch {
+	case int(address)+length <= len(s.cfg.Bin):
+		copy(out, s.cfg.Bin[address:])
+	case address >= sramStart && int(address-sramStart)+length <= sramSize:
+		s.mu.Lock()
+		copy(out, s.sram[address-sramStart:])
+		s.mu.Unlock()
+	default:
+		return nil, fmt.Errorf("address 0x%X out of range", address)
+	}
+	return out, nil
+}
+
+func (s *T7) log(str string) {
+	if s.cfg.OnMessage != nil {
+		s.cfg.OnMessage(str)
+	}
+}
+
+func (s *T7) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.close:
+			return
+		case f := <-s.send:
+			s.log(f.String())
+			s.handle(f)
+		}
+	}
+}
+
+func (s *T7) reply(id uint32, data []byte) {
+	time.Sleep(s.cfg.Latency)
+	f := gocan.NewFrame(id, data, gocan.Incoming)
+	s.log(f.String())
+	select {
+	case s.recv <- f:
+	case <-s.close:
+	}
+}
+
+func (s *T7) handle(f gocan.CANFrame) {
+	d := f.Data()
+	if len(d) < 4 {
+		return
+	}
+	switch f.Identifier() {
+	case 0x220, 0x222:
+		if d[1] == kwp2000.START_COMMUNICATION {
+			s.session = true
+			s.lastRequest = time.Now()
+			s.securityGrant = false
+			s.pending = nil
+			s.reply(0x238, []byte{0x40, 0xBF, 0x06, kwp2000.START_COMMUNICATION | 0x40, 0x00, 0x11, 0x02, 0x58})
+		}
+	case 0x240, 0x242:
+		s.mu.Lock()
+		dropped := s.dropSession
+		s.dropSession = false
+		s.mu.Unlock()
+		if dropped || s.session && s.cfg.SessionTimeout > 0 && time.Since(s.lastRequest) > s.cfg.SessionTimeout {
+			s.session = false
+			s.releaseOutputs()
+		}
+		if !s.session {
+			return
+		}
+		s.lastRequest = time.Now()
+		s.handleRequestFrame(d)
+	case 0x266:
+		s.handleAck(d)
+	}
+}
+
+func (s *T7) handleRequestFrame(d []byte) {
+	if d[0]&0x40 == 0x40 {
+		s.request = s.request[:0]
+	}
+	s.request = append(s.request, d[2:]...)
+	if d[0]&0x80 == 0x80 {
+		s.reply(0x270, []byte{0x40, 0xBF, 0x3F, d[0] & 0xBF, 0x00, 0x00, 0x00, 0x00})
+	}
+	if d[0]&0x3F != 0 {
+		return
+	}
+	end := 1 + int(s.request[0])
+	// testers send the DynamicallyDefineLocalIdentifier length without the service id, the ECU accepts it anyway
+	if s.request[1] == kwp2000.DYNAMICALLY_DEFINE_LOCAL_IDENTIFIER {
+		end++
+	}
+	if end < 2 || end > len(s.request) {
+		return
+	}
+	service := s.request[1]
+	if s.busy < s.cfg.BusyResponses {
+		s.busy++
+		s.sendResponse(negative(service, kwp2000.BUSY_REPEAT_REQUEST))
+		return
+	}
+	if s.cfg.ResponsePending > 0 && (service == kwp2000.START_ROUTINE_BY_LOCAL_IDENTIFIER || service == kwp2000.READ_DATA_BY_LOCAL_IDENTIFIER) {
+		s.sendResponse(negative(service, kwp2000.REQUEST_CORRECTLY_RECEIVED_RESPONSE_PENDING))
+		time.Sleep(s.cfg.ResponsePending)
+	}
+	resp := s.handleService(service, s.request[2:end])
+	if resp != nil {
+		s.sendResponse(resp)
+	}
+}
+
+// handleAck sends the next frame of a multi-frame response when the tester acks the previous one
+func (s *T7) handleAck(d []byte) {
+	if len(s.pending) == 0 || d[2] != 0x3F {
+		return
+	}
+	if s.pending[0].Data()[0]&0xBF != d[3] {
+		return
+	}
+	s.pending = s.pending[1:]
+	if len(s.pending) > 0 {
+		s.reply(0x258, s.pending[0].Data())
+	}
+}
+
+// sendResponse splits a response into 0x258 frames, frames after the first are sent as the tester acks them
+func (s *T7) sendResponse(payload []byte) {
+	msg := append([]byte{byte(len(payload))}, payload...)
+	count := (len(msg) + 5) / 6
+	s.pending = s.pending[:0]
+	for i := 0; i < count; i++ {
+		data := make([]byte, 8)
+		data[0] = byte(count-i-1) & 0x3F
+		if i == 0 {
+			data[0] |= 0x40
+		}
+		if i == count-1 {
+			data[0] |= 0x80
+		}
+		data[1] = 0xBF
+		copy(data[2:], msg[i*6:])
+		s.pending = append(s.pending, gocan.NewFrame(0x258, data, gocan.Incoming))
+	}
+	first := s.pending[0]
+	if count == 1 {
+		s.pending = nil
+	}
+	s.reply(0x258, first.Data())
+}
+
+func negative(service, code byte) []byte {
+	return []byte{0x7F, service, code}
+}
+
+func (s *T7) handleService(service byte, data []byte) []byte {
+	switch service {
+	case kwp2000.STOP_COMMUNICATION:
+		s.session = false
+		s.releaseOutputs()
+		return nil
+	case kwp2000.TESTER_PRESENT:
+		return []byte{service | 0x40}
+	case kwp2000.SECURITY_ACCESS:
+		return s.securityAccess(data)
+	case kwp2000.DYNAMICALLY_DEFINE_LOCAL_IDENTIFIER:
+		return s.dynamicallyDefineLocalId(data)
+	case kwp2000.READ_DATA_BY_LOCAL_IDENTIFIER:
+		return s.readDataByLocalIdentifier(data)
+	case kwp2000.START_ROUTINE_BY_LOCAL_IDENTIFIER:
+		if len(data) < 1 || data[0] != 0x50 {
+			return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+		}
+		if !s.securityGrant {
+			return negative(service, kwp2000.SECURITY_ACCESS_DENIED_OR_REQUESTED)
+		}
+		if s.symTable == nil {
+			return negative(service, kwp2000.CONDITIONS_NOT_CORRECT_OR_REQUEST_SEQUENCE_ERROR)
+		}
+		s.symTblPos = 0
+		return []byte{service | 0x40, data[0]}
+	case kwp2000.TRANSFER_DATA:
+		return s.transferData()
+	case kwp2000.REQUEST_TRANSFER_EXIT:
+		return []byte{service | 0x40}
+	case kwp2000.READ_DIAGNOSTIC_TROUBLE_CODES_BY_STATUS:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		out := []byte{service | 0x40, byte(len(s.dtcs))}
+		for _, dtc := range s.dtcs {
+			out = append(out, byte(dtc.Code>>8), byte(dtc.Code), dtc.Status)
+		}
+		return out
+	case kwp2000.READ_ECU_IDENTIFICATION:
+		return s.readECUIdentification(data)
+	case kwp2000.WRITE_MEMORY_BY_ADDRESS:
+		return s.writeMemoryByAddress(data)
+	case kwp2000.INPUT_OUTPUT_CONTROL_BY_LOCAL_IDENTIFIER:
+		return s.inputOutputControl(data)
+	case kwp2000.READ_FREEZEFRAME_DATA:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(data) < 2 || int(data[0]) >= len(s.freezeFrames) {
+			return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+		}
+		return append([]byte{service | 0x40, data[0]}, s.freezeFrames[data[0]]...)
+	case kwp2000.CLEAR_DIAGNOSTIC_INFORMATION:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.dtcs = nil
+		s.freezeFrames = nil
+		return append([]byte{service | 0x40}, data...)
+	case kwp2000.ECU_RESET:
+		s.session = false
+		return []byte{service | 0x40, 0x81}
+	default:
+		return negative(service, kwp2000.SERVICE_NOT_SUPPORTED)
+	}
+}
+
+func (s *T7) readECUIdentification(data []byte) []byte {
+	const service = kwp2000.READ_ECU_IDENTIFICATION
+	if len(data) < 1 {
+		return negative(service, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+	}
+	var value string
+	switch data[0] {
+	case kwp2000.ID_VIN:
+		value = s.cfg.Identification.VIN
+	case kwp2000.ID_HARDWARE_NUMBER:
+		value = s.cfg.Identification.HardwareNumber
+	case kwp2000.ID_IMMOBILIZER_CODE:
+		value = s.cfg.Identification.ImmobilizerCode
+	case kwp2000.ID_SOFTWARE_PART_NUMBER:
+		value = s.cfg.Identification.SoftwarePartNumber
+	case kwp2000.ID_SOFTWARE_VERSION:
+		value = s.cfg.Identification.SoftwareVersion
+	case kwp2000.ID_ENGINE_TYPE:
+		value = s.cfg.Identification.EngineType
+	default:
+		return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+	}
+	return append([]byte{service | 0x40, data[0]}, value...)
+}
+
+func (s *T7) writeMemoryByAddress(data []byte) []byte {
+	const service = kwp2000.WRITE_MEMORY_BY_ADDRESS
+	if !s.securityGrant {
+		return negative(service, kwp2000.SECURITY_ACCESS_DENIED_OR_REQUESTED)
+	}
+	if len(data) < 5 || len(data)-4 != int(data[3]) {
+		return negative(service, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+	}
+	address := uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+	if err := s.SetMemory(address, data[4:]); err != nil {
+		return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+	}
+	return []byte{service | 0x40, data[0], data[1], data[2]}
+}
+
+func (s *T7) inputOutputControl(data []byte) []byte {
+	const service = kwp2000.INPUT_OUTPUT_CONTROL_BY_LOCAL_IDENTIFIER
+	if len(data) < 2 {
+		return negative(service, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+	}
+	if len(s.cfg.Outputs) > 0 && !bytes.Contains(s.cfg.Outputs, data[:1]) {
+		return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch data[1] {
+	case kwp2000.IO_RETURN_CONTROL_TO_ECU:
+		delete(s.outputs, data[0])
+		return []byte{service | 0x40, data[0], data[1]}
+	case kwp2000.IO_REPORT_CURRENT_STATE:
+		return []byte{service | 0x40, data[0], data[1], s.outputs[data[0]]}
+	case kwp2000.IO_SHORT_TERM_ADJUSTMENT:
+		if !s.securityGrant {
+			return negative(service, kwp2000.SECURITY_ACCESS_DENIED_OR_REQUESTED)
+		}
+		if len(data) < 3 {
+			return negative(service, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+		}
+		s.outputs[data[0]] = data[2]
+		return []byte{service | 0x40, data[0], data[1], data[2]}
+	}
+	return negative(service, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+}
+
+func (s *T7) securityAccess(data []byte) []byte {
+	if len(data) < 1 {
+		return negative(kwp2000.SECURITY_ACCESS, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+	}
+	switch data[0] {
+	case 0x05:
+		s.seed = uint16(rand.Intn(0xFFFF))
+		return []byte{0x67, 0x05, byte(s.seed >> 8), byte(s.seed)}
+	case 0x06:
+		if len(data) < 3 {
+			return negative(kwp2000.SECURITY_ACCESS, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+		}
+		key := uint16(data[1])<<8 | uint16(data[2])
+		if key != securityKey(s.seed, s.cfg.KeyMethod) {
+			return negative(kwp2000.SECURITY_ACCESS, kwp2000.INVALID_KEY)
+		}
+		s.securityGrant = true
+		return []byte{0x67, 0x06, 0x34}
+	}
+	return negative(kwp2000.SECURITY_ACCESS, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+}
+
+// securityKey is the ECU side of the seed/key exchange
+func securityKey(seed uint16, method int) uint16 {
+	key := seed << 2
+	switch method {
+	case 1:
+		key ^= 0x4081
+		key -= 0x1F6F
+	case 2:
+		key ^= 0x3DC
+		key -= 0x2356
+	case 3:
+		key ^= 0x3D7
+		key -= 0x2356
+	case 4:
+		key ^= 0x409
+		key -= 0x2356
+	default:
+		key ^= 0x8142
+		key -= 0x2356
+	}
+	return key
+}
+
+func (s *T7) dynamicallyDefineLocalId(data []byte) []byte {
+	const service = kwp2000.DYNAMICALLY_DEFINE_LOCAL_IDENTIFIER
+	if len(data) < 6 || data[0] < 0xF0 || data[0] > 0xF7 || data[1] != 0x03 {
+		return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+	}
+	localID, idx := data[0], int(data[2])
+	if idx > len(s.dynamic[localID]) {
+		return negative(service, kwp2000.CONDITIONS_NOT_CORRECT_OR_REQUEST_SEQUENCE_ERROR)
+	}
+	var entry dynamicEntry
+	switch {
+	case data[3] != 0x00: // by address, length given
+		entry.length = int(data[3])
+		entry.address = uint32(data[4])<<16 | uint32(data[5])<<8
+		if len(data) > 6 {
+			entry.address |= uint32(data[6])
+		}
+	case len(data) >= 7 && data[4] == 0x80: // by symbol number
+		no := int(data[5])<<8 | int(data[6])
+		if no >= len(s.symbols) {
+			return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+		}
+		entry.address = s.symbols[no].Address
+		entry.length = int(s.symbols[no].Length)
+	default:
+		return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+	}
+	// defining an index drops every entry after it, so index 0 starts a new list
+	if s.dynamic == nil {
+		s.dynamic = make(map[byte][]dynamicEntry)
+	}
+	s.dynamic[localID] = append(s.dynamic[localID][:idx], entry)
+	return []byte{service | 0x40, localID}
+}
+
+func (s *T7) readDataByLocalIdentifier(data []byte) []byte {
+	const service = kwp2000.READ_DATA_BY_LOCAL_IDENTIFIER
+	if len(data) < 1 || len(s.dynamic[data[0]]) == 0 {
+		return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+	}
+	out := []byte{service | 0x40, data[0]}
+	for _, e := range s.dynamic[data[0]] {
+		b, err := s.readMemory(e.address, e.length)
+		if err != nil {
+			return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+		}
+		out = append(out, b...)
+	}
+	if len(out) > 0xFE {
+		return negative(service, kwp2000.REQUEST_OUT_OF_RANGE)
+	}
+	return out
+}
+
+func (s *T7) transferData() []byte {
+	const service = kwp2000.TRANSFER_DATA
+	if s.symTable == nil {
+		return negative(service, kwp2000.CONDITIONS_NOT_CORRECT_OR_REQUEST_SEQUENCE_ERROR)
+	}
+	left := len(s.symTable) - s.symTblPos
+	if left == 0 {
+		// a single frame response marks the end of the table
+		return []byte{service | 0x40, 0x31, 0x50, 0x76}
+	}
+	n := transferBlockSize
+	if left < n {
+		n = left
+	}
+	// a block needs to span at least two frames, don't leave a single byte for the last one
+	if left-n == 1 {
+		n--
+	}
+	pos := s.symTblPos
+	s.symTblPos += n
+	return append([]byte{service | 0x40, byte(pos >> 16), byte(pos >> 8), byte(pos)}, s.symTable[pos:pos+n]...)
+}
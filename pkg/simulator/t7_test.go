@@ -0,0 +1,207 @@
+package simulator_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/ecu"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+	"github.com/roffe/t7logger/pkg/simulator"
+)
+
+const (
+	testAddressTable = 0x40000
+	testNameTable    = 0x50000
+	testSRAM         = 0xF00100
+)
+
+// testBin builds a 512 KB binary with the packed symbol table layout the symbol package looks for,
+// the names come from the lzhuf fixture of the symbol package and every symbol gets 2 bytes of SRAM
+func testBin(t *testing.T) ([]byte, []string) {
+	t.Helper()
+	lz, err := os.ReadFile("../symbol/testdata/symbols.lzhuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt, err := os.ReadFile("../symbol/testdata/symbols.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := strings.Split(strings.TrimSuffix(string(txt), "\r\n"), "\r\n")
+
+	bin := bytes.Repeat([]byte{0xFF}, 0x80000)
+	copy(bin[testNameTable:], lz)
+
+	table := bytes.NewBuffer(nil)
+	// the first two entries hold the name table location followed by the marker the table is found by,
+	// the name table length has to be above 0x1000 so it is padded with the flash after it
+	binary.Write(table, binary.BigEndian, uint32(testNameTable))
+	binary.Write(table, binary.BigEndian, uint16(0x1234))
+	table.Write([]byte{0x00, 0x00, 0x04, 0x00})
+	table.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0x20, 0x00})
+	for i := 2; i < len(names); i++ {
+		binary.Write(table, binary.BigEndian, uint32(testSRAM+i*2))
+		binary.Write(table, binary.BigEndian, uint16(2))
+		table.Write([]byte{0x00, 0x00, 0x00, 0x00})
+	}
+	table.Write([]byte{'S', 'C', 0, 0, 0, 0, 0, 0, 0, 0})
+	copy(bin[testAddressTable:], table.Bytes())
+	return bin, names
+}
+
+func newTestT7(t *testing.T) (*simulator.T7, []string) {
+	t.Helper()
+	bin, names := testBin(t)
+	sim, err := simulator.NewT7(simulator.T7Config{Bin: bin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sim.Close() })
+	if len(sim.Symbols()) != len(names) {
+		t.Fatalf("simulator loaded %d symbols, want %d", len(sim.Symbols()), len(names))
+	}
+	return sim, names
+}
+
+func TestT7KWP(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sim, names := newTestT7(t)
+	for i, name := range names[2:5] {
+		if err := sim.SetSymbol(name, []byte{0x12, byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cl, err := gocan.New(ctx, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	k := kwp2000.New(cl)
+	if err := k.StartSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer k.StopSession(ctx)
+
+	granted, err := k.RequestSecurityAccess(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !granted {
+		t.Fatal("security access not granted")
+	}
+	if err := k.TesterPresent(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		v := &kwp2000.VarDefinition{Name: names[i+2], Method: kwp2000.VAR_METHOD_SYMBOL, Value: i + 2, Length: 2}
+		if err := k.DynamicallyDefineLocalIdRequest(ctx, i, v); err != nil {
+			t.Fatal(err)
+		}
+		// the positive response is not waited for, give it time like the datalogger does
+		time.Sleep(5 * time.Millisecond)
+	}
+	data, err := k.ReadDataByLocalIdentifier(ctx, 0xF0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x12, 0x00, 0x12, 0x01, 0x12, 0x02}; !bytes.Equal(data, want) {
+		t.Errorf("local id 0xF0 returned % X, want % X", data, want)
+	}
+
+	data, err = k.ReadMemoryByAddress(ctx, testSRAM+2*2, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x12, 0x00, 0x12, 0x01, 0x12, 0x02}; !bytes.Equal(data, want) {
+		t.Errorf("SRAM read returned % X, want % X", data, want)
+	}
+
+	bin, _ := testBin(t)
+	data, err = k.ReadMemoryByAddress(ctx, testAddressTable, 0x20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, bin[testAddressTable:testAddressTable+0x20]) {
+		t.Errorf("flash read returned % X, want % X", data, bin[testAddressTable:testAddressTable+0x20])
+	}
+}
+
+func TestT7GetSymbols(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	sim, names := newTestT7(t)
+	symbols, err := ecu.GetSymbols(ctx, sim, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != len(names) {
+		t.Fatalf("got %d symbols, want %d", len(symbols), len(names))
+	}
+	for i, sym := range symbols {
+		if sym.Name != names[i] {
+			t.Errorf("symbol %d is named %q, want %q", i, sym.Name, names[i])
+		}
+		if i >= 2 && (sym.Address != uint32(testSRAM+i*2) || sym.Length != 2) {
+			t.Errorf("%s at 0x%X length %d, want 0x%X length 2", sym.Name, sym.Address, sym.Length, testSRAM+i*2)
+		}
+	}
+}
+
+func TestT7WriteVar(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sim, names := newTestT7(t)
+	v := &kwp2000.VarDefinition{Name: names[5], Method: kwp2000.VAR_METHOD_SYMBOL, Value: 5, Length: 2, Correctionfactor: "0.1"}
+	data, err := v.Encode(123.4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := ecu.ResolveAddress(v, sim.Symbols())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Method != kwp2000.VAR_METHOD_ADDRESS || resolved.Value != testSRAM+5*2 {
+		t.Fatalf("%s resolved to %s 0x%X, want address 0x%X", v.Name, resolved.Method, resolved.Value, testSRAM+5*2)
+	}
+
+	cl, err := gocan.New(ctx, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	k := kwp2000.New(cl)
+	if err := k.StartSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer k.StopSession(ctx)
+	if granted, err := k.RequestSecurityAccess(ctx, false); err != nil || !granted {
+		t.Fatalf("security access not granted: %v", err)
+	}
+
+	if err := k.WriteVar(ctx, v, data); err == nil {
+		t.Error("writing by symbol number did not fail")
+	}
+	if err := k.WriteVar(ctx, resolved, data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := k.ReadMemoryByAddress(ctx, resolved.Value, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x04, 0xD2}; !bytes.Equal(got, want) {
+		t.Errorf("RAM holds % X after the write, want % X", got, want)
+	}
+}
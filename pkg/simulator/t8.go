@@ -0,0 +1,355 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+	"github.com/roffe/t7logger/pkg/t8"
+)
+
+const (
+	t8SramStart = 0x100000
+	t8SramSize  = 0x10000
+)
+
+// T8 is a simulated Trionic 8 ECU that implements gocan.Adapter.
+//
+// Requests are read from 0x7E0 and answered on 0x7E8, both ISO-TP framed,
+// data packets are sent on 0x5E8
+type T8 struct {
+	cfg T8Config
+
+	send, recv chan gocan.CANFrame
+	close      chan struct{}
+	closeOnce  sync.Once
+
+	mu   sync.Mutex
+	sram []byte
+
+	securityGrant bool
+	seed          uint16
+
+	request    []byte // multi-frame request being received
+	requestLen int
+	response   []byte // multi-frame response waiting for flow control
+	packets    map[byte]dynamicEntry
+}
+
+type T8Config struct {
+	// Latency is how long the ECU waits before sending each frame
+	Latency time.Duration
+	// OnMessage gets called with every frame sent and received when set
+	OnMessage func(string)
+	// Identification is served by ReadDataByIdentifier, defaults are used for empty fields
+	Identification kwp2000.ECUIdentification
+}
+
+func NewT8(cfg T8Config) *T8 {
+	if cfg.Latency == 0 {
+		cfg.Latency = time.Millisecond
+	}
+	for _, f := range []struct {
+		dst *string
+		def string
+	}{
+		{&cfg.Identification.VIN, "YS3FF45S581000001"},
+		{&cfg.Identification.SoftwareVersion, "FA5I_C_0302"},
+		{&cfg.Identification.SoftwarePartNumber, "12794524"},
+		{&cfg.Identification.HardwareNumber, "12788714"},
+		{&cfg.Identification.EngineType, "B207R"},
+	} {
+		if *f.dst == "" {
+			*f.dst = f.def
+		}
+	}
+	return &T8{
+		cfg:     cfg,
+		send:    make(chan gocan.CANFrame, 10),
+		recv:    make(chan gocan.CANFrame, 20),
+		close:   make(chan struct{}),
+		sram:    make([]byte, t8SramSize),
+		packets: make(map[byte]dynamicEntry),
+	}
+}
+
+func (s *T8) Name() string {
+	return "T8 Simulator"
+}
+
+func (s *T8) Init(ctx context.Context) error {
+	go s.run(ctx)
+	return nil
+}
+
+func (s *T8) SetFilter(filters []uint32) error {
+	return nil
+}
+
+func (s *T8) Recv() <-chan gocan.CANFrame {
+	return s.recv
+}
+
+func (s *T8) Send() chan<- gocan.CANFrame {
+	return s.send
+}
+
+func (s *T8) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.close)
+	})
+	return nil
+}
+
+// SetMemory writes data to the simulated SRAM
+func (s *T8) SetMemory(address uint32, data []byte) error {
+	if address < t8SramStart || int(address-t8SramStart)+len(data) > t8SramSize {
+		return fmt.Errorf("address 0x%X outside of SRAM", address)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copy(s.sram[address-t8SramStart:], data)
+	return nil
+}
+
+// Memory returns length bytes of the simulated SRAM
+func (s *T8) Memory(address uint32, length int) ([]byte, error) {
+	if address < t8SramStart || int(address-t8SramStart)+length > t8SramSize {
+		return nil, fmt.Errorf("address 0x%X outside of SRAM", address)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte(nil), s.sram[address-t8SramStart:int(address-t8SramStart)+length]...), nil
+}
+
+// Reset forgets security access and all defined packets as if the ECU had lost power
+func (s *T8) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.securityGrant = false
+	s.packets = make(map[byte]dynamicEntry)
+}
+
+func (s *T8) log(str string) {
+	if s.cfg.OnMessage != nil {
+		s.cfg.OnMessage(str)
+	}
+}
+
+func (s *T8) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.close:
+			return
+		case f := <-s.send:
+			s.log(f.String())
+			s.handle(f)
+		}
+	}
+}
+
+func (s *T8) reply(id uint32, data []byte) {
+	time.Sleep(s.cfg.Latency)
+	f := gocan.NewFrame(id, data, gocan.Incoming)
+	s.log(f.String())
+	select {
+	case s.recv <- f:
+	case <-s.close:
+	}
+}
+
+func (s *T8) handle(f gocan.CANFrame) {
+	if f.Identifier() != t8.REQ_MSG_ID {
+		return
+	}
+	d := f.Data()
+	if len(d) != 8 {
+		return
+	}
+	switch d[0] & 0xF0 {
+	case 0x00: // single frame
+		if l := int(d[0]); l > 0 && l < 8 {
+			s.handleService(d[1 : 1+l])
+		}
+	case 0x10: // first frame
+		s.requestLen = int(d[0]&0x0F)<<8 | int(d[1])
+		s.request = append([]byte(nil), d[2:]...)
+		s.reply(t8.RESP_MSG_ID, []byte{0x30, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	case 0x20: // consecutive frame
+		if s.request == nil {
+			return
+		}
+		s.request = append(s.request, d[1:]...)
+		if len(s.request) >= s.requestLen {
+			req := s.request[:s.requestLen]
+			s.request = nil
+			s.handleService(req)
+		}
+	case 0x30: // flow control for a multi-frame response
+		resp := s.response
+		s.response = nil
+		var seq byte = 1
+		for len(resp) > 0 {
+			n := len(resp)
+			if n > 7 {
+				n = 7
+			}
+			s.reply(t8.RESP_MSG_ID, padFrame(append([]byte{0x20 | seq}, resp[:n]...)))
+			resp = resp[n:]
+			seq = (seq + 1) & 0x0F
+		}
+	}
+}
+
+func (s *T8) sendResponse(payload []byte) {
+	if len(payload) <= 7 {
+		s.reply(t8.RESP_MSG_ID, padFrame(append([]byte{byte(len(payload))}, payload...)))
+		return
+	}
+	s.reply(t8.RESP_MSG_ID, append([]byte{0x10 | byte(len(payload)>>8), byte(len(payload))}, payload[:6]...))
+	s.response = payload[6:]
+}
+
+func padFrame(d []byte) []byte {
+	for len(d) < 8 {
+		d = append(d, 0x00)
+	}
+	return d
+}
+
+func (s *T8) handleService(req []byte) {
+	service, data := req[0], req[1:]
+	var resp []byte
+	switch service {
+	case t8.TESTER_PRESENT, t8.RETURN_TO_NORMAL_MODE:
+		if service == t8.RETURN_TO_NORMAL_MODE {
+			s.Reset()
+		}
+		resp = []byte{service | 0x40}
+	case t8.READ_DATA_BY_IDENTIFIER:
+		resp = s.readDataByIdentifier(data)
+	case t8.SECURITY_ACCESS:
+		resp = s.securityAccess(data)
+	case t8.DYNAMICALLY_DEFINE_MESSAGE:
+		resp = s.dynamicallyDefineMessage(data)
+	case t8.READ_DATA_BY_PACKET_IDENTIFIER:
+		resp = s.readDataByPacketIdentifier(data)
+	case t8.WRITE_MEMORY_BY_ADDRESS:
+		resp = s.writeMemoryByAddress(data)
+	default:
+		resp = t8Negative(service, kwp2000.SERVICE_NOT_SUPPORTED)
+	}
+	if resp != nil {
+		s.sendResponse(resp)
+	}
+}
+
+func t8Negative(service, code byte) []byte {
+	return []byte{t8.NEGATIVE_RESPONSE, service, code}
+}
+
+func (s *T8) readDataByIdentifier(data []byte) []byte {
+	if len(data) != 1 {
+		return t8Negative(t8.READ_DATA_BY_IDENTIFIER, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+	}
+	var value string
+	switch data[0] {
+	case t8.ID_VIN:
+		value = s.cfg.Identification.VIN
+	case t8.ID_SOFTWARE_VERSION:
+		value = s.cfg.Identification.SoftwareVersion
+	case t8.ID_SOFTWARE_PART_NUMBER:
+		value = s.cfg.Identification.SoftwarePartNumber
+	case t8.ID_HARDWARE_NUMBER:
+		value = s.cfg.Identification.HardwareNumber
+	case t8.ID_ENGINE_TYPE:
+		value = s.cfg.Identification.EngineType
+	default:
+		return t8Negative(t8.READ_DATA_BY_IDENTIFIER, kwp2000.REQUEST_OUT_OF_RANGE)
+	}
+	return append([]byte{t8.READ_DATA_BY_IDENTIFIER | 0x40, data[0]}, value...)
+}
+
+func (s *T8) securityAccess(data []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case len(data) == 1 && data[0] == 0x01:
+		if s.securityGrant {
+			return []byte{t8.SECURITY_ACCESS | 0x40, 0x01, 0x00, 0x00}
+		}
+		s.seed = uint16(time.Now().UnixNano()) | 0x0101
+		return []byte{t8.SECURITY_ACCESS | 0x40, 0x01, byte(s.seed >> 8), byte(s.seed)}
+	case len(data) == 3 && data[0] == 0x02:
+		key := s.seed>>5 | s.seed<<11
+		key += 0xB988
+		if uint16(data[1])<<8|uint16(data[2]) != key {
+			return t8Negative(t8.SECURITY_ACCESS, kwp2000.INVALID_KEY)
+		}
+		s.securityGrant = true
+		return []byte{t8.SECURITY_ACCESS | 0x40, 0x02}
+	}
+	return t8Negative(t8.SECURITY_ACCESS, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+}
+
+func (s *T8) dynamicallyDefineMessage(data []byte) []byte {
+	if len(data) != 6 || data[5] == 0 || data[5] > t8.MaxPacketSize {
+		return t8Negative(t8.DYNAMICALLY_DEFINE_MESSAGE, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.securityGrant {
+		return t8Negative(t8.DYNAMICALLY_DEFINE_MESSAGE, kwp2000.SECURITY_ACCESS_DENIED_OR_REQUESTED)
+	}
+	address := uint32(data[1])<<24 | uint32(data[2])<<16 | uint32(data[3])<<8 | uint32(data[4])
+	if address < t8SramStart || int(address-t8SramStart)+int(data[5]) > t8SramSize {
+		return t8Negative(t8.DYNAMICALLY_DEFINE_MESSAGE, kwp2000.REQUEST_OUT_OF_RANGE)
+	}
+	s.packets[data[0]] = dynamicEntry{address: address, length: int(data[5])}
+	return []byte{t8.DYNAMICALLY_DEFINE_MESSAGE | 0x40, data[0]}
+}
+
+// readDataByPacketIdentifier sends each packet as a single frame on the UUDT id, there is no USDT response
+func (s *T8) readDataByPacketIdentifier(data []byte) []byte {
+	if len(data) < 2 || data[0] != 0x01 {
+		return t8Negative(t8.READ_DATA_BY_PACKET_IDENTIFIER, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+	}
+	var frames [][]byte
+	s.mu.Lock()
+	for _, dpid := range data[1:] {
+		p, ok := s.packets[dpid]
+		if !ok {
+			s.mu.Unlock()
+			return t8Negative(t8.READ_DATA_BY_PACKET_IDENTIFIER, kwp2000.REQUEST_OUT_OF_RANGE)
+		}
+		off := int(p.address - t8SramStart)
+		frames = append(frames, padFrame(append([]byte{dpid}, s.sram[off:off+p.length]...)))
+	}
+	s.mu.Unlock()
+	for _, f := range frames {
+		s.reply(t8.UUDT_RESP_MSG_ID, f)
+	}
+	return nil
+}
+
+func (s *T8) writeMemoryByAddress(data []byte) []byte {
+	if len(data) < 5 {
+		return t8Negative(t8.WRITE_MEMORY_BY_ADDRESS, kwp2000.SUBFUNCTION_NOT_SUPPORTED_OR_INVALID_FORMAT)
+	}
+	s.mu.Lock()
+	granted := s.securityGrant
+	s.mu.Unlock()
+	if !granted {
+		return t8Negative(t8.WRITE_MEMORY_BY_ADDRESS, kwp2000.SECURITY_ACCESS_DENIED_OR_REQUESTED)
+	}
+	address := uint32(data[0])<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+	if err := s.SetMemory(address, data[4:]); err != nil {
+		return t8Negative(t8.WRITE_MEMORY_BY_ADDRESS, kwp2000.REQUEST_OUT_OF_RANGE)
+	}
+	return append([]byte{t8.WRITE_MEMORY_BY_ADDRESS | 0x40}, data[:4]...)
+}
@@ -0,0 +1,331 @@
+package symbol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Port of the decoder in lzhuf/lzhuf.c (Yoshizaki/Okumura LZHUF) used by Trionic 7
+// to compress the symbol name table
+
+const (
+	lzN         = 4096 // buffer size
+	lzF         = 60   // lookahead buffer size
+	lzThreshold = 2
+
+	lzNChar   = 256 - lzThreshold + lzF // kinds of characters (character code = 0..N_CHAR-1)
+	lzT       = lzNChar*2 - 1           // size of table
+	lzR       = lzT - 1                 // position of root
+	lzMaxFreq = 0x8000                  // updates tree when the root frequency comes to this value
+)
+
+// tables for decoding the upper 6 bits of position
+var lzDCode = [256]byte{
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01,
+	0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01,
+	0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02,
+	0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02,
+	0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03,
+	0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03,
+	0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04,
+	0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05,
+	0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06,
+	0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07,
+	0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08,
+	0x09, 0x09, 0x09, 0x09, 0x09, 0x09, 0x09, 0x09,
+	0x0A, 0x0A, 0x0A, 0x0A, 0x0A, 0x0A, 0x0A, 0x0A,
+	0x0B, 0x0B, 0x0B, 0x0B, 0x0B, 0x0B, 0x0B, 0x0B,
+	0x0C, 0x0C, 0x0C, 0x0C, 0x0D, 0x0D, 0x0D, 0x0D,
+	0x0E, 0x0E, 0x0E, 0x0E, 0x0F, 0x0F, 0x0F, 0x0F,
+	0x10, 0x10, 0x10, 0x10, 0x11, 0x11, 0x11, 0x11,
+	0x12, 0x12, 0x12, 0x12, 0x13, 0x13, 0x13, 0x13,
+	0x14, 0x14, 0x14, 0x14, 0x15, 0x15, 0x15, 0x15,
+	0x16, 0x16, 0x16, 0x16, 0x17, 0x17, 0x17, 0x17,
+	0x18, 0x18, 0x19, 0x19, 0x1A, 0x1A, 0x1B, 0x1B,
+	0x1C, 0x1C, 0x1D, 0x1D, 0x1E, 0x1E, 0x1F, 0x1F,
+	0x20, 0x20, 0x21, 0x21, 0x22, 0x22, 0x23, 0x23,
+	0x24, 0x24, 0x25, 0x25, 0x26, 0x26, 0x27, 0x27,
+	0x28, 0x28, 0x29, 0x29, 0x2A, 0x2A, 0x2B, 0x2B,
+	0x2C, 0x2C, 0x2D, 0x2D, 0x2E, 0x2E, 0x2F, 0x2F,
+	0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37,
+	0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F,
+}
+
+var lzDLen = [256]byte{
+	0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03,
+	0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03,
+	0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03,
+	0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03,
+	0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04,
+	0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04,
+	0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04,
+	0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04,
+	0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04,
+	0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04,
+	0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05,
+	0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05,
+	0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05,
+	0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05,
+	0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05,
+	0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05,
+	0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05,
+	0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05,
+	0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06,
+	0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06,
+	0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06,
+	0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06,
+	0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06,
+	0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06, 0x06,
+	0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07,
+	0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07,
+	0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07,
+	0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07,
+	0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07,
+	0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07, 0x07,
+	0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08,
+	0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08, 0x08,
+}
+
+var (
+	ErrLZHUFTruncated = errors.New("lzhuf: compressed data truncated")
+	ErrLZHUFTooLarge  = errors.New("lzhuf: uncompressed size too large")
+)
+
+// LZHUFMaxSize caps the uncompressed size read from the header, the name table
+// of a 512 KiB T7 binary is a fraction of this
+const LZHUFMaxSize = 4 << 20
+
+type lzhufDecoder struct {
+	in  []byte
+	pos int
+
+	getbuf uint16
+	getlen uint8
+
+	freq [lzT + 1]uint16 // frequency table
+	prnt [lzT + lzNChar]uint16
+	son  [lzT]uint16
+
+	textBuf [lzN + lzF - 1]byte
+}
+
+// LZHUFDecodedLength returns the uncompressed size stored in the 4 byte little endian header
+func LZHUFDecodedLength(in []byte) (int, error) {
+	if len(in) < 4 {
+		return 0, ErrLZHUFTruncated
+	}
+	size := binary.LittleEndian.Uint32(in)
+	if size > LZHUFMaxSize {
+		return 0, fmt.Errorf("%w: %d bytes", ErrLZHUFTooLarge, size)
+	}
+	return int(size), nil
+}
+
+// LZHUFDecode expands data compressed with lzhuf.c Encode, the first 4 bytes hold the uncompressed size
+func LZHUFDecode(in []byte) ([]byte, error) {
+	textsize, err := LZHUFDecodedLength(in)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, textsize)
+	if textsize == 0 {
+		return out, nil
+	}
+
+	d := &lzhufDecoder{in: in[4:]}
+	d.startHuff()
+
+	for i := 0; i < lzN-lzF; i++ {
+		d.textBuf[i] = ' '
+	}
+
+	r := lzN - lzF
+	for count := 0; count < textsize; {
+		c := d.decodeChar()
+		if c < 256 {
+			out[count] = byte(c)
+			d.textBuf[r] = byte(c)
+			r = (r + 1) & (lzN - 1)
+			count++
+		} else {
+			i := (r - int(d.decodePosition()) - 1) & (lzN - 1)
+			j := int(c) - 255 + lzThreshold
+			for k := 0; k < j && count < textsize; k++ {
+				b := d.textBuf[(i+k)&(lzN-1)]
+				out[count] = b
+				d.textBuf[r] = b
+				r = (r + 1) & (lzN - 1)
+				count++
+			}
+		}
+		// the bit reader keeps up to two bytes buffered ahead of what is decoded
+		if d.pos > len(d.in)+2 {
+			return nil, fmt.Errorf("%w after %d of %d bytes", ErrLZHUFTruncated, count, textsize)
+		}
+	}
+	return out, nil
+}
+
+func (d *lzhufDecoder) nextByte() uint16 {
+	var b uint16
+	if d.pos < len(d.in) {
+		b = uint16(d.in[d.pos])
+	}
+	d.pos++
+	return b
+}
+
+// get one bit
+func (d *lzhufDecoder) getBit() uint16 {
+	for d.getlen <= 8 {
+		d.getbuf |= d.nextByte() << (8 - d.getlen)
+		d.getlen += 8
+	}
+	i := d.getbuf
+	d.getbuf <<= 1
+	d.getlen--
+	return i >> 15
+}
+
+// get one byte
+func (d *lzhufDecoder) getByte() uint16 {
+	for d.getlen <= 8 {
+		d.getbuf |= d.nextByte() << (8 - d.getlen)
+		d.getlen += 8
+	}
+	i := d.getbuf
+	d.getbuf <<= 8
+	d.getlen -= 8
+	return i >> 8
+}
+
+// initialization of tree
+func (d *lzhufDecoder) startHuff() {
+	for i := 0; i < lzNChar; i++ {
+		d.freq[i] = 1
+		d.son[i] = uint16(i + lzT)
+		d.prnt[i+lzT] = uint16(i)
+	}
+	i, j := 0, lzNChar
+	for j <= lzR {
+		d.freq[j] = d.freq[i] + d.freq[i+1]
+		d.son[j] = uint16(i)
+		d.prnt[i] = uint16(j)
+		d.prnt[i+1] = uint16(j)
+		i += 2
+		j++
+	}
+	d.freq[lzT] = 0xFFFF
+	d.prnt[lzR] = 0
+}
+
+// reconstruction of tree
+func (d *lzhufDecoder) reconst() {
+	// collect leaf nodes in the first half of the table
+	// and replace the freq by (freq + 1) / 2
+	j := 0
+	for i := 0; i < lzT; i++ {
+		if d.son[i] >= lzT {
+			d.freq[j] = (d.freq[i] + 1) / 2
+			d.son[j] = d.son[i]
+			j++
+		}
+	}
+	// begin constructing tree by connecting sons
+	for i, j := 0, lzNChar; j < lzT; i, j = i+2, j+1 {
+		f := d.freq[i] + d.freq[i+1]
+		d.freq[j] = f
+		k := j - 1
+		for f < d.freq[k] {
+			k--
+		}
+		k++
+		copy(d.freq[k+1:j+1], d.freq[k:j])
+		d.freq[k] = f
+		copy(d.son[k+1:j+1], d.son[k:j])
+		d.son[k] = uint16(i)
+	}
+	// connect prnt
+	for i := 0; i < lzT; i++ {
+		if k := int(d.son[i]); k >= lzT {
+			d.prnt[k] = uint16(i)
+		} else {
+			d.prnt[k] = uint16(i)
+			d.prnt[k+1] = uint16(i)
+		}
+	}
+}
+
+// increment frequency of given code by one, and update tree
+func (d *lzhufDecoder) update(code uint16) {
+	if d.freq[lzR] == lzMaxFreq {
+		d.reconst()
+	}
+	c := int(d.prnt[int(code)+lzT])
+	for {
+		d.freq[c]++
+		k := d.freq[c]
+
+		// if the order is disturbed, exchange nodes
+		if l := c + 1; k > d.freq[l] {
+			for l++; k > d.freq[l]; l++ {
+			}
+			l--
+			d.freq[c] = d.freq[l]
+			d.freq[l] = k
+
+			i := int(d.son[c])
+			d.prnt[i] = uint16(l)
+			if i < lzT {
+				d.prnt[i+1] = uint16(l)
+			}
+
+			j := int(d.son[l])
+			d.son[l] = uint16(i)
+
+			d.prnt[j] = uint16(c)
+			if j < lzT {
+				d.prnt[j+1] = uint16(c)
+			}
+			d.son[c] = uint16(j)
+
+			c = l
+		}
+		// repeat up to root
+		if c = int(d.prnt[c]); c == 0 {
+			break
+		}
+	}
+}
+
+func (d *lzhufDecoder) decodeChar() uint16 {
+	c := d.son[lzR]
+
+	// travel from root to leaf, choosing the smaller child node (son[])
+	// if the read bit is 0, the bigger (son[]+1) if 1
+	for c < lzT {
+		c += d.getBit()
+		c = d.son[c]
+	}
+	c -= lzT
+	d.update(c)
+	return c
+}
+
+func (d *lzhufDecoder) decodePosition() uint16 {
+	// recover upper 6 bits from table
+	i := d.getByte()
+	c := uint16(lzDCode[i]) << 6
+	j := lzDLen[i]
+
+	// read lower 6 bits verbatim
+	for j -= 2; j > 0; j-- {
+		i = (i << 1) + d.getBit()
+	}
+	return c | (i & 0x3F)
+}
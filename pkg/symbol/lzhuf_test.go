@@ -0,0 +1,139 @@
+package symbol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/symbols.lzhuf is a Trionic 7 style name table compressed with Encode from
+// lzhuf/lzhuf.c, testdata/symbols.txt is what Decode from lzhuf/lzhuf.c expands it to
+func TestLZHUFDecodeMatchesC(t *testing.T) {
+	in, err := os.ReadFile("testdata/symbols.lzhuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/symbols.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := LZHUFDecodedLength(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(want) {
+		t.Fatalf("decoded length %d, want %d", n, len(want))
+	}
+	got, err := LZHUFDecode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		for i := range got {
+			if i >= len(want) || got[i] != want[i] {
+				t.Fatalf("output differs from lzhuf.c at byte %d", i)
+			}
+		}
+		t.Fatalf("output is %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestLZHUFDecodeTruncated(t *testing.T) {
+	in, err := os.ReadFile("testdata/symbols.lzhuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 3, len(in) / 2} {
+		if _, err := LZHUFDecode(in[:n]); !errors.Is(err, ErrLZHUFTruncated) {
+			t.Errorf("%d bytes: got %v, want ErrLZHUFTruncated", n, err)
+		}
+	}
+}
+
+func TestLZHUFDecodeTooLarge(t *testing.T) {
+	in := make([]byte, 8)
+	binary.LittleEndian.PutUint32(in, 0xFFFFFFFF)
+	if _, err := LZHUFDecode(in); !errors.Is(err, ErrLZHUFTooLarge) {
+		t.Fatalf("got %v, want ErrLZHUFTooLarge", err)
+	}
+}
+
+func TestLZHUFDecodeEmpty(t *testing.T) {
+	out, err := LZHUFDecode(make([]byte, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Fatalf("got %d bytes, want 0", len(out))
+	}
+}
+
+// TestLZHUFRoundTripC compresses with lzhuf/lzhuf.c and expands with LZHUFDecode,
+// it needs a C compiler and is skipped without one
+func TestLZHUFRoundTripC(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+	bin := filepath.Join(t.TempDir(), "lzhuf")
+	if out, err := exec.Command(cc, "-o", bin, "testdata/lzhuf.c").CombinedOutput(); err != nil {
+		t.Fatalf("building lzhuf.c: %v\n%s", err, out)
+	}
+	encode := func(in []byte) []byte {
+		cmd := exec.Command(bin, "e")
+		cmd.Stdin = bytes.NewReader(in)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	names, err := os.ReadFile("testdata/symbols.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := os.ReadFile("testdata/symbols.lzhuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encode(names), fixture) {
+		t.Error("testdata/symbols.lzhuf is not the lzhuf.c compression of testdata/symbols.txt")
+	}
+
+	// a table the size of a real one spans the 4 KB window many times over
+	large := bytes.NewBuffer(nil)
+	for i := 0; large.Len() < 64<<10; i++ {
+		for _, name := range bytes.Split(names, []byte("\r\n")) {
+			fmt.Fprintf(large, "%s%d\r\n", name, i)
+		}
+	}
+	random := make([]byte, 10<<10)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	for _, tt := range []struct {
+		name string
+		in   []byte
+	}{
+		{"names", names},
+		{"large table", large.Bytes()},
+		{"random", random},
+		{"repeated byte", bytes.Repeat([]byte{'A'}, 5000)},
+		{"one byte", []byte{'x'}},
+	} {
+		got, err := LZHUFDecode(encode(tt.in))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.in) {
+			t.Errorf("%s: decoded %d bytes that differ from the %d bytes compressed", tt.name, len(got), len(tt.in))
+		}
+	}
+}
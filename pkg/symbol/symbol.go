@@ -11,8 +11,6 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"syscall"
-	"unsafe"
 )
 
 type Symbol struct {
@@ -205,40 +203,15 @@ func ExpandCompressedSymbolNames(in []byte) ([]string, error) {
 	//	log.Println(err)
 	//}
 	//readNo++
-	var expandedFileSize int
-	for i := 0; i < 4; i++ {
-		expandedFileSize |= int(in[i]) << uint(i*8)
-	}
-	out := make([]byte, expandedFileSize)
-
-	dll, err := syscall.LoadDLL("lzhuf.dll")
-	if err != nil {
-		log.Println(err)
-		return nil, fmt.Errorf("error loading lzhuf.dll: %w", err)
-	}
-	defer dll.Release()
-
-	decode, err := dll.FindProc("Decode")
+	out, err := LZHUFDecode(in)
 	if err != nil {
-		log.Println(err)
-		return nil, fmt.Errorf("error finding Decode in lzhuf.dll: %w", err)
-	}
-
-	r0, r1, err := decode.Call(uintptr(unsafe.Pointer(&in[0])), uintptr(unsafe.Pointer(&out[0])))
-	if r1 == 0 {
-		if err != nil {
-			return nil, fmt.Errorf("error decoding compressed symbol table: %w", err)
-		}
+		return nil, fmt.Errorf("error decoding compressed symbol table: %w", err)
 	}
 
 	//if err := os.WriteFile("uncompressed-"+strconv.Itoa(readNo)+".bin", out, 0644); err != nil {
 	//	log.Println(err)
 	//}
 
-	if int(r0) != expandedFileSize {
-		return nil, fmt.Errorf("decoded data size missmatch: %d != %d", r0, expandedFileSize)
-	}
-
 	return strings.Split(string(out), "\r\n"), nil
 }
 
@@ -274,6 +247,20 @@ func extractCompressedSymbolTable(cb func(string), file io.ReadSeeker) (bool, in
 	return false, -1, nil, errors.New("ecst: symbol table not found")
 }
 
+// CompressedSymbolTableLocation returns the flash offset and length of the compressed symbol name table
+func CompressedSymbolTableLocation(file io.ReadSeeker) (int, int, error) {
+	addressTableOffset := bytePatternSearch(file, searchPattern, 0x30000) - 0x06
+	if addressTableOffset < 0 {
+		return -1, -1, errors.New("could not find addressTableOffset table")
+	}
+	symbolTableOffset := getAddressFromOffset(file, addressTableOffset)
+	symbolTableLength := getLengthFromOffset(file, addressTableOffset+0x04)
+	if symbolTableLength > 0x1000 && symbolTableOffset > 0 && symbolTableOffset < 0x70000 {
+		return symbolTableOffset, symbolTableLength, nil
+	}
+	return -1, -1, errors.New("symbol table not found")
+}
+
 func getLengthFromOffset(file io.ReadSeeker, offset int) int {
 	file.Seek(int64(offset), io.SeekStart)
 	var val uint16
@@ -0,0 +1,39 @@
+/*
+ lzhuf.c from the repository root as a filter for the tests,
+ "lzhuf e" compresses stdin to stdout and "lzhuf d" expands it
+ */
+
+#include "../../../lzhuf/lzhuf.c"
+
+int main(int argc, char *argv[])
+{
+    unsigned char *in, *out;
+    unsigned int inlen = 0, outlen, size = 1 << 16;
+    size_t n;
+
+    if (argc != 2 || (argv[1][0] != 'e' && argv[1][0] != 'd'))
+    {
+        fprintf(stderr, "usage: lzhuf e|d < in > out\n");
+        return 2;
+    }
+    in = malloc(size);
+    while ((n = fread(in + inlen, 1, size - inlen, stdin)) > 0)
+    {
+        inlen += n;
+        if (inlen == size)
+            in = realloc(in, size *= 2);
+    }
+    if (argv[1][0] == 'e')
+    {
+        /* the output of lzhuf can be larger than the input */
+        out = calloc(inlen * 2 + 16, 1);
+        outlen = Encode(in, inlen, out);
+    }
+    else
+    {
+        out = malloc(DecodedLength(in) + 1);
+        outlen = Decode(in, out);
+    }
+    fwrite(out, 1, outlen, stdout);
+    return 0;
+}
@@ -0,0 +1,13 @@
+package t5
+
+import "time"
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout sets how long to wait for a response, defaults to 150ms
+func WithTimeout(d time.Duration) Option {
+	return func(t *Client) {
+		t.defaultTimeout = d
+	}
+}
@@ -0,0 +1,198 @@
+package t5
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/symbol"
+)
+
+// CAN ids and bus speed used by Trionic 5
+var (
+	REQ_MSG_ID  uint32 = 0x005
+	RESP_MSG_ID uint32 = 0x00C
+)
+
+// CANRate is the Trionic 5 bus speed in kbit/s
+const CANRate = 615.384
+
+// Commands, the first byte of every frame
+const (
+	SEND_COMMAND_BYTE = 0xC4
+	READ_RESPONSE     = 0xC6
+	READ_MEMORY       = 0xC7
+)
+
+const (
+	// MaxReadMemory is the number of bytes returned by one read memory request
+	MaxReadMemory = 6
+	// symbolTableEnd marks the end of the symbol table sent by the ECU
+	symbolTableEnd = "END\r\n"
+)
+
+// ErrUnexpectedResponse is returned when the ECU answers with another command than the one sent
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// Client talks to a Trionic 5 using its CAN protocol. Every request is a single frame
+// answered by a single frame, text commands are sent and their output read one byte at a time
+type Client struct {
+	c *gocan.Client
+
+	defaultTimeout time.Duration
+
+	mu sync.Mutex
+}
+
+func New(c *gocan.Client, opts ...Option) *Client {
+	t := &Client{
+		c:              c,
+		defaultTimeout: 150 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *Client) request(ctx context.Context, payload []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	resp, err := t.c.SendAndPoll(ctx, gocan.NewFrame(REQ_MSG_ID, payload, gocan.ResponseRequired), t.defaultTimeout, RESP_MSG_ID)
+	if err != nil {
+		return nil, err
+	}
+	d := resp.Data()
+	if len(d) != 8 {
+		return nil, fmt.Errorf("%w: expected 8 bytes, got %X", ErrUnexpectedResponse, d)
+	}
+	if d[0] != payload[0] {
+		return nil, fmt.Errorf("%w: %X to command %02X", ErrUnexpectedResponse, d, payload[0])
+	}
+	return d, nil
+}
+
+// SendCommand sends a text command to the ECU one byte at a time
+func (t *Client) SendCommand(ctx context.Context, cmd string) error {
+	for i := 0; i < len(cmd); i++ {
+		// the ECU answers every command byte with read response and status 0
+		t.mu.Lock()
+		resp, err := t.c.SendAndPoll(ctx, gocan.NewFrame(REQ_MSG_ID, []byte{SEND_COMMAND_BYTE, cmd[i], 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, gocan.ResponseRequired), t.defaultTimeout, RESP_MSG_ID)
+		t.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("SendCommand: %w", err)
+		}
+		if d := resp.Data(); len(d) < 2 || d[0] != READ_RESPONSE || d[1] != 0x00 {
+			return fmt.Errorf("SendCommand: %w: %X", ErrUnexpectedResponse, d)
+		}
+	}
+	return nil
+}
+
+// ReadResponseByte reads the next byte of the output of a text command
+func (t *Client) ReadResponseByte(ctx context.Context) (byte, error) {
+	d, err := t.request(ctx, []byte{READ_RESPONSE, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	if err != nil {
+		return 0, fmt.Errorf("ReadResponseByte: %w", err)
+	}
+	if d[1] != 0x00 {
+		return 0, fmt.Errorf("ReadResponseByte: ECU reports error %02X", d[1])
+	}
+	return d[2], nil
+}
+
+// StartSession checks that the ECU answers, T5 has no session to be opened
+func (t *Client) StartSession(ctx context.Context) error {
+	if _, err := t.ReadMemory(ctx, 0, 1); err != nil {
+		return fmt.Errorf("StartSession: %w", err)
+	}
+	return nil
+}
+
+// ReadMemory reads length bytes of RAM from address
+func (t *Client) ReadMemory(ctx context.Context, address uint32, length int) ([]byte, error) {
+	out := make([]byte, 0, length)
+	for len(out) < length {
+		b, err := t.readMemory(ctx, address+uint32(len(out)))
+		if err != nil {
+			return nil, fmt.Errorf("ReadMemory: %w", err)
+		}
+		n := length - len(out)
+		if n > MaxReadMemory {
+			n = MaxReadMemory
+		}
+		out = append(out, b[:n]...)
+	}
+	return out, nil
+}
+
+// readMemory reads the 6 bytes from address, the ECU is asked for the last address of the block
+// and returns the bytes backwards from it
+func (t *Client) readMemory(ctx context.Context, address uint32) ([]byte, error) {
+	end := address + MaxReadMemory - 1
+	d, err := t.request(ctx, []byte{READ_MEMORY, byte(end >> 24), byte(end >> 16), byte(end >> 8), byte(end), 0x00, 0x00, 0x00})
+	if err != nil {
+		return nil, err
+	}
+	if d[1] != 0x00 {
+		return nil, fmt.Errorf("ECU reports error %02X reading 0x%X", d[1], address)
+	}
+	out := make([]byte, MaxReadMemory)
+	for i := range out {
+		out[i] = d[7-i]
+	}
+	return out, nil
+}
+
+// GetSymbolTable asks the ECU for its symbol table, every line holds the address and length
+// as four hex digits each followed by the name
+func (t *Client) GetSymbolTable(ctx context.Context, cb func(string)) ([]*symbol.Symbol, error) {
+	if err := t.SendCommand(ctx, "S\r"); err != nil {
+		return nil, fmt.Errorf("GetSymbolTable: %w", err)
+	}
+	buff := bytes.NewBuffer(nil)
+	for !bytes.HasSuffix(buff.Bytes(), []byte(symbolTableEnd)) {
+		b, err := t.ReadResponseByte(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("GetSymbolTable: %w", err)
+		}
+		buff.WriteByte(b)
+		if buff.Len()%1024 == 0 {
+			cb(fmt.Sprintf("Read %d bytes of symbol table", buff.Len()))
+		}
+	}
+	return parseSymbolTable(strings.TrimSuffix(buff.String(), symbolTableEnd))
+}
+
+func parseSymbolTable(table string) ([]*symbol.Symbol, error) {
+	var symbols []*symbol.Symbol
+	for i, line := range strings.Split(table, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if len(line) < 9 {
+			return nil, fmt.Errorf("invalid symbol table line %d: %q", i, line)
+		}
+		address, err := strconv.ParseUint(line[:4], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid symbol table line %d: %w", i, err)
+		}
+		length, err := strconv.ParseUint(line[4:8], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid symbol table line %d: %w", i, err)
+		}
+		symbols = append(symbols, &symbol.Symbol{
+			Name:    line[8:],
+			Number:  len(symbols),
+			Address: uint32(address),
+			Length:  uint16(length),
+		})
+	}
+	return symbols, nil
+}
@@ -0,0 +1,46 @@
+package t8
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+// Data identifiers for the ECU identification
+const (
+	ID_VIN                  = 0x90
+	ID_HARDWARE_NUMBER      = 0x92
+	ID_SOFTWARE_VERSION     = 0x95
+	ID_ENGINE_TYPE          = 0x97
+	ID_SOFTWARE_PART_NUMBER = 0xC1
+)
+
+// ReadECUIdentification reads VIN, software, hardware and engine type from the ECU.
+// Only the VIN is required, T8 has no immobilizer code to report
+func (t *Client) ReadECUIdentification(ctx context.Context) (*kwp2000.ECUIdentification, error) {
+	vin, err := t.ReadDataByIdentifier(ctx, ID_VIN)
+	if err != nil {
+		return nil, err
+	}
+	ident := &kwp2000.ECUIdentification{VIN: identString(vin)}
+	for _, opt := range []struct {
+		id  byte
+		dst *string
+	}{
+		{ID_SOFTWARE_VERSION, &ident.SoftwareVersion},
+		{ID_SOFTWARE_PART_NUMBER, &ident.SoftwarePartNumber},
+		{ID_HARDWARE_NUMBER, &ident.HardwareNumber},
+		{ID_ENGINE_TYPE, &ident.EngineType},
+	} {
+		if data, err := t.ReadDataByIdentifier(ctx, opt.id); err == nil {
+			*opt.dst = identString(data)
+		}
+	}
+	return ident, nil
+}
+
+func identString(data []byte) string {
+	return strings.TrimSpace(string(bytes.Trim(data, "\x00\xFF")))
+}
@@ -0,0 +1,194 @@
+package t8
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+// SendRequest sends a request to service and returns the positive response, everything after the service id
+func (t *Client) SendRequest(ctx context.Context, service byte, data []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flush()
+	if err := t.sendPayload(append([]byte{service}, data...)); err != nil {
+		return nil, err
+	}
+	timeout := t.defaultTimeout
+	for {
+		resp, err := t.recvPayload(ctx, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if resp[0] == NEGATIVE_RESPONSE {
+			if len(resp) < 3 {
+				return nil, fmt.Errorf("%w: short negative response %X", kwp2000.ErrInvalidFrame, resp)
+			}
+			if resp[2] == kwp2000.REQUEST_CORRECTLY_RECEIVED_RESPONSE_PENDING {
+				timeout = t.pendingTimeout
+				continue
+			}
+			return nil, negativeResponse(resp[1], resp[2], resp)
+		}
+		if resp[0] != service|0x40 {
+			return nil, fmt.Errorf("%w: unexpected response %X to service %02X", kwp2000.ErrInvalidFrame, resp, service)
+		}
+		return resp[1:], nil
+	}
+}
+
+// flush drops frames left over from an earlier request that timed out
+func (t *Client) flush() {
+	for {
+		select {
+		case <-t.incoming:
+		default:
+			return
+		}
+	}
+}
+
+// sendPayload sends payload as a single frame, or a first frame followed by consecutive frames
+// at the pace the ECU asks for in its flow control frames
+func (t *Client) sendPayload(payload []byte) error {
+	if len(payload) <= 7 {
+		return t.c.Send(gocan.NewFrame(t.reqID, pad(append([]byte{byte(len(payload))}, payload...)), gocan.Outgoing))
+	}
+	if len(payload) > 0xFFF {
+		return fmt.Errorf("request too long: %d bytes", len(payload))
+	}
+	first := append([]byte{0x10 | byte(len(payload)>>8), byte(len(payload))}, payload[:6]...)
+	if err := t.c.Send(gocan.NewFrame(t.reqID, first, gocan.Outgoing)); err != nil {
+		return err
+	}
+	payload = payload[6:]
+	var seq byte = 1
+	for len(payload) > 0 {
+		blockSize, separation, err := t.waitFlowControl()
+		if err != nil {
+			return err
+		}
+		for n := 0; len(payload) > 0 && (blockSize == 0 || n < blockSize); n++ {
+			l := len(payload)
+			if l > 7 {
+				l = 7
+			}
+			if err := t.c.Send(gocan.NewFrame(t.reqID, pad(append([]byte{0x20 | seq}, payload[:l]...)), gocan.Outgoing)); err != nil {
+				return err
+			}
+			payload = payload[l:]
+			seq = (seq + 1) & 0x0F
+			time.Sleep(separation)
+		}
+	}
+	return nil
+}
+
+func (t *Client) waitFlowControl() (int, time.Duration, error) {
+	timeout := time.After(t.defaultTimeout)
+	for {
+		select {
+		case f := <-t.incoming:
+			d := f.Data()
+			if f.Identifier() != t.respID {
+				continue
+			}
+			if len(d) < 3 || d[0]&0xF0 != 0x30 {
+				return 0, 0, fmt.Errorf("%w: expected flow control, got %X", kwp2000.ErrInvalidFrame, d)
+			}
+			if d[0] == 0x31 {
+				// wait
+				timeout = time.After(t.defaultTimeout)
+				continue
+			}
+			if d[0] != 0x30 {
+				return 0, 0, fmt.Errorf("ECU refused request with flow control %X", d)
+			}
+			separation := time.Duration(d[2]) * time.Millisecond
+			if d[2] > 0x7F {
+				separation = 0
+			}
+			return int(d[1]), separation, nil
+		case <-timeout:
+			return 0, 0, fmt.Errorf("timeout waiting for flow control")
+		}
+	}
+}
+
+// recvPayload receives a single or multi-frame response, consecutive frames are requested all at once
+func (t *Client) recvPayload(ctx context.Context, timeout time.Duration) ([]byte, error) {
+	d, err := t.recvFrame(ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+	switch d[0] & 0xF0 {
+	case 0x00:
+		l := int(d[0])
+		if l == 0 || len(d) < l+1 {
+			return nil, fmt.Errorf("%w: single frame %X", kwp2000.ErrInvalidFrame, d)
+		}
+		return d[1 : l+1], nil
+	case 0x10:
+		if len(d) != 8 {
+			return nil, fmt.Errorf("%w: first frame %X", kwp2000.ErrInvalidFrame, d)
+		}
+		l := int(d[0]&0x0F)<<8 | int(d[1])
+		if l < 7 {
+			return nil, fmt.Errorf("%w: first frame %X", kwp2000.ErrInvalidFrame, d)
+		}
+		out := append(make([]byte, 0, l), d[2:]...)
+		if err := t.c.Send(gocan.NewFrame(t.reqID, []byte{0x30, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, gocan.Outgoing)); err != nil {
+			return nil, err
+		}
+		var seq byte = 1
+		for len(out) < l {
+			d, err := t.recvFrame(ctx, t.defaultTimeout)
+			if err != nil {
+				return nil, err
+			}
+			if d[0] != 0x20|seq {
+				return nil, fmt.Errorf("%w: frame %02X out of sequence, expected %02X", kwp2000.ErrInvalidFrame, d[0], 0x20|seq)
+			}
+			n := l - len(out)
+			if n > len(d)-1 {
+				n = len(d) - 1
+			}
+			out = append(out, d[1:1+n]...)
+			seq = (seq + 1) & 0x0F
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected frame %X", kwp2000.ErrInvalidFrame, d)
+	}
+}
+
+func (t *Client) recvFrame(ctx context.Context, timeout time.Duration) ([]byte, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, fmt.Errorf("timeout waiting for response")
+		case f := <-t.incoming:
+			if f.Identifier() != t.respID {
+				continue
+			}
+			if d := f.Data(); len(d) > 0 {
+				return d, nil
+			}
+		}
+	}
+}
+
+// pad fills a frame up to 8 bytes
+func pad(d []byte) []byte {
+	for len(d) < 8 {
+		d = append(d, 0x00)
+	}
+	return d
+}
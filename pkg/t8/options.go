@@ -0,0 +1,38 @@
+package t8
+
+import "time"
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithIDs sets the CAN ids requests are sent on and responses and data packets are received on,
+// defaults to REQ_MSG_ID, RESP_MSG_ID and UUDT_RESP_MSG_ID
+func WithIDs(reqID, respID, uudtID uint32) Option {
+	return func(t *Client) {
+		t.reqID = reqID
+		t.respID = respID
+		t.uudtID = uudtID
+	}
+}
+
+// WithTimeout sets how long to wait for a response, defaults to 150ms
+func WithTimeout(d time.Duration) Option {
+	return func(t *Client) {
+		t.defaultTimeout = d
+	}
+}
+
+// WithReadDataTimeout sets the timeout for ReadDataByPacketIdentifier, which is kept short
+// so a lost frame only costs one sample while logging, defaults to 50ms
+func WithReadDataTimeout(d time.Duration) Option {
+	return func(t *Client) {
+		t.readDataTimeout = d
+	}
+}
+
+// WithResponsePendingTimeout sets how long to wait for the real response after the ECU answered response pending, defaults to 5s
+func WithResponsePendingTimeout(d time.Duration) Option {
+	return func(t *Client) {
+		t.pendingTimeout = d
+	}
+}
@@ -0,0 +1,95 @@
+package t8
+
+import (
+	"fmt"
+
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+const (
+	// MaxPacketSize is the most data one packet carries, the first byte of the frame holds the dpid
+	MaxPacketSize = 7
+	// data packet identifiers available to the tester
+	firstDPID = 0x01
+	lastDPID  = 0xFE
+)
+
+// Packet is a dynamically defined data packet holding Size bytes of a variable from Offset
+type Packet struct {
+	ID      byte
+	Address uint32
+	Size    int
+	Offset  int
+	Var     *kwp2000.VarDefinition
+}
+
+// PacketSet is the packets defined for a set of variables
+type PacketSet struct {
+	Packets []*Packet
+}
+
+// SplitPackets spreads variables over packets, a variable larger than MaxPacketSize takes several.
+// Packets are defined by memory address so only variables using the address method can be logged
+func SplitPackets(vars []*kwp2000.VarDefinition) (*PacketSet, error) {
+	set := &PacketSet{}
+	id := firstDPID
+	for _, v := range vars {
+		if v.Method != kwp2000.VAR_METHOD_ADDRESS {
+			return nil, fmt.Errorf("SplitPackets: %s uses method %s, T8 can only log by address", v.Name, v.Method)
+		}
+		if v.Length == 0 {
+			return nil, fmt.Errorf("SplitPackets: %s has no length", v.Name)
+		}
+		for off := 0; off < int(v.Length); off += MaxPacketSize {
+			if id > lastDPID {
+				return nil, fmt.Errorf("SplitPackets: variables need more than %d packets", lastDPID-firstDPID+1)
+			}
+			size := int(v.Length) - off
+			if size > MaxPacketSize {
+				size = MaxPacketSize
+			}
+			set.Packets = append(set.Packets, &Packet{
+				ID:      byte(id),
+				Address: uint32(v.Value + off),
+				Size:    size,
+				Offset:  off,
+				Var:     v,
+			})
+			id++
+		}
+	}
+	return set, nil
+}
+
+// IDs returns the dpids of all packets in order
+func (s *PacketSet) IDs() []byte {
+	ids := make([]byte, len(s.Packets))
+	for i, p := range s.Packets {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// Read decodes packet data by dpid into the variables, the variables only change if all packets are present
+func (s *PacketSet) Read(data map[byte][]byte) error {
+	buffers := make(map[*kwp2000.VarDefinition][]byte)
+	for _, p := range s.Packets {
+		d, ok := data[p.ID]
+		if !ok {
+			return fmt.Errorf("packet %02X missing", p.ID)
+		}
+		if len(d) < p.Size {
+			return fmt.Errorf("packet %02X: expected %d bytes, got %d", p.ID, p.Size, len(d))
+		}
+		buf, ok := buffers[p.Var]
+		if !ok {
+			buf = make([]byte, p.Var.Length)
+			buffers[p.Var] = buf
+		}
+		copy(buf[p.Offset:], d[:p.Size])
+	}
+	for v, buf := range buffers {
+		v.Set(buf)
+	}
+	return nil
+}
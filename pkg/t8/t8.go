@@ -0,0 +1,232 @@
+package t8
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+// CAN ids used by Trionic 8 for diagnostics over GMLAN
+var (
+	REQ_MSG_ID        uint32 = 0x7E0
+	RESP_MSG_ID       uint32 = 0x7E8
+	UUDT_RESP_MSG_ID  uint32 = 0x5E8
+	FUNCTIONAL_MSG_ID uint32 = 0x101
+)
+
+// GMLAN service ids
+const (
+	INITIATE_DIAGNOSTIC_OPERATION  = 0x10
+	READ_DATA_BY_IDENTIFIER        = 0x1A
+	RETURN_TO_NORMAL_MODE          = 0x20
+	SECURITY_ACCESS                = 0x27
+	DYNAMICALLY_DEFINE_MESSAGE     = 0x2C
+	WRITE_MEMORY_BY_ADDRESS        = 0x3D
+	TESTER_PRESENT                 = 0x3E
+	READ_DATA_BY_PACKET_IDENTIFIER = 0xAA
+	NEGATIVE_RESPONSE              = 0x7F
+)
+
+// Client talks to a Trionic 8 over GMLAN, requests and responses are ISO-TP (ISO 15765-2)
+// framed, data packets are sent as unacknowledged single frames on a separate id
+type Client struct {
+	c *gocan.Client
+
+	reqID  uint32
+	respID uint32
+	uudtID uint32
+
+	defaultTimeout  time.Duration
+	readDataTimeout time.Duration
+	pendingTimeout  time.Duration
+
+	incoming chan gocan.CANFrame
+
+	// mu is held for the duration of a request so the keep-alive never interleaves with it
+	mu sync.Mutex
+}
+
+func New(c *gocan.Client, opts ...Option) *Client {
+	t := &Client{
+		c:               c,
+		reqID:           REQ_MSG_ID,
+		respID:          RESP_MSG_ID,
+		uudtID:          UUDT_RESP_MSG_ID,
+		defaultTimeout:  150 * time.Millisecond,
+		readDataTimeout: 50 * time.Millisecond,
+		pendingTimeout:  5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	// consecutive frames follow each other without any ack, subscribe once so none are missed between polls
+	t.incoming = c.Subscribe(context.Background(), t.respID, t.uudtID)
+	return t
+}
+
+// StartSession checks that the ECU answers, GMLAN needs no session to be opened for diagnostic services
+func (t *Client) StartSession(ctx context.Context) error {
+	if _, err := t.SendRequest(ctx, TESTER_PRESENT, nil); err != nil {
+		return fmt.Errorf("StartSession: %w", err)
+	}
+	return nil
+}
+
+// StopSession returns the ECU to normal mode, which also clears all dynamically defined packets
+func (t *Client) StopSession(ctx context.Context) error {
+	if _, err := t.SendRequest(ctx, RETURN_TO_NORMAL_MODE, nil); err != nil {
+		return fmt.Errorf("StopSession: %w", err)
+	}
+	return nil
+}
+
+// TesterPresent keeps the diagnostic state of all nodes alive, it is sent functionally and never answered
+func (t *Client) TesterPresent() error {
+	return t.c.Send(gocan.NewFrame(FUNCTIONAL_MSG_ID, []byte{0xFE, 0x01, TESTER_PRESENT, 0x00, 0x00, 0x00, 0x00, 0x00}, gocan.Outgoing))
+}
+
+// KeepAlive sends TesterPresent every interval until ctx is done
+func (t *Client) KeepAlive(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.mu.Lock()
+				t.TesterPresent()
+				t.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// RequestSecurityAccess unlocks the services that need security access at level 0x01
+func (t *Client) RequestSecurityAccess(ctx context.Context) (bool, error) {
+	seed, err := t.SendRequest(ctx, SECURITY_ACCESS, []byte{0x01})
+	if err != nil {
+		return false, fmt.Errorf("RequestSecurityAccess: %w", err)
+	}
+	if len(seed) != 3 || seed[0] != 0x01 {
+		return false, fmt.Errorf("RequestSecurityAccess: invalid seed response %X", seed)
+	}
+	if seed[1] == 0x00 && seed[2] == 0x00 {
+		// access was already granted
+		return true, nil
+	}
+	key := calculateKey(uint16(seed[1])<<8 | uint16(seed[2]))
+	if _, err := t.SendRequest(ctx, SECURITY_ACCESS, []byte{0x02, byte(key >> 8), byte(key)}); err != nil {
+		if errors.Is(err, kwp2000.ErrInvalidKey) {
+			return false, nil
+		}
+		return false, fmt.Errorf("RequestSecurityAccess: %w", err)
+	}
+	return true, nil
+}
+
+func calculateKey(seed uint16) uint16 {
+	key := seed>>5 | seed<<11
+	return key + 0xB988
+}
+
+// ReadDataByIdentifier reads static data such as identification strings
+func (t *Client) ReadDataByIdentifier(ctx context.Context, id byte) ([]byte, error) {
+	resp, err := t.SendRequest(ctx, READ_DATA_BY_IDENTIFIER, []byte{id})
+	if err != nil {
+		return nil, fmt.Errorf("ReadDataByIdentifier: %w", err)
+	}
+	if len(resp) < 1 || resp[0] != id {
+		return nil, fmt.Errorf("ReadDataByIdentifier: invalid response for 0x%02X: %X", id, resp)
+	}
+	return resp[1:], nil
+}
+
+// DynamicallyDefineMessage defines the data packet dpid to hold size bytes read from address
+func (t *Client) DynamicallyDefineMessage(ctx context.Context, dpid byte, address uint32, size int) error {
+	if size < 1 || size > MaxPacketSize {
+		return fmt.Errorf("DynamicallyDefineMessage: invalid size %d", size)
+	}
+	data := []byte{dpid, byte(address >> 24), byte(address >> 16), byte(address >> 8), byte(address), byte(size)}
+	resp, err := t.SendRequest(ctx, DYNAMICALLY_DEFINE_MESSAGE, data)
+	if err != nil {
+		return fmt.Errorf("DynamicallyDefineMessage: %w", err)
+	}
+	if len(resp) < 1 || resp[0] != dpid {
+		return fmt.Errorf("DynamicallyDefineMessage: invalid response for 0x%02X: %X", dpid, resp)
+	}
+	return nil
+}
+
+// ReadDataByPacketIdentifier requests each packet once and returns their data by dpid,
+// requests are split so they fit in a single frame
+func (t *Client) ReadDataByPacketIdentifier(ctx context.Context, dpids ...byte) (map[byte][]byte, error) {
+	out := make(map[byte][]byte, len(dpids))
+	for len(dpids) > 0 {
+		n := len(dpids)
+		if n > 5 {
+			n = 5
+		}
+		if err := t.readPackets(ctx, dpids[:n], out); err != nil {
+			return nil, fmt.Errorf("ReadDataByPacketIdentifier: %w", err)
+		}
+		dpids = dpids[n:]
+	}
+	return out, nil
+}
+
+func (t *Client) readPackets(ctx context.Context, dpids []byte, out map[byte][]byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flush()
+	// sendOneResponse
+	if err := t.sendPayload(append([]byte{READ_DATA_BY_PACKET_IDENTIFIER, 0x01}, dpids...)); err != nil {
+		return err
+	}
+	left := len(dpids)
+	timeout := time.NewTimer(t.readDataTimeout)
+	defer timeout.Stop()
+	for left > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout.C:
+			return fmt.Errorf("timeout waiting for %d of %d packets", left, len(dpids))
+		case f := <-t.incoming:
+			d := f.Data()
+			if f.Identifier() == t.respID {
+				if len(d) > 3 && d[1] == NEGATIVE_RESPONSE && d[2] == READ_DATA_BY_PACKET_IDENTIFIER {
+					return negativeResponse(d[2], d[3], d)
+				}
+				continue
+			}
+			if len(d) < 2 {
+				return fmt.Errorf("%w: %X", kwp2000.ErrInvalidFrame, d)
+			}
+			if _, seen := out[d[0]]; !seen {
+				out[d[0]] = d[1:]
+				left--
+			}
+		}
+	}
+	return nil
+}
+
+// WriteMemoryByAddress writes data to RAM, security access has to be granted first
+func (t *Client) WriteMemoryByAddress(ctx context.Context, address uint32, data []byte) error {
+	payload := append([]byte{byte(address >> 24), byte(address >> 16), byte(address >> 8), byte(address)}, data...)
+	if _, err := t.SendRequest(ctx, WRITE_MEMORY_BY_ADDRESS, payload); err != nil {
+		return fmt.Errorf("WriteMemoryByAddress: %w", err)
+	}
+	return nil
+}
+
+func negativeResponse(service, code byte, d []byte) error {
+	return &kwp2000.NegativeResponseError{ServiceID: service, Code: code, Frame: append([]byte(nil), d...)}
+}
@@ -21,6 +21,7 @@ type VarDefinitionWidget struct {
 	symbolNumber           *widget.Entry
 	symbolType             *widget.Entry
 	symbolSigned           *widget.Check
+	symbolMask             *widget.Entry
 	symbolCorrectionfactor *widget.Entry
 	symbolGroup            *widget.Entry
 	symbolDeleteBTN        *widget.Button
@@ -72,6 +73,24 @@ func NewVarDefinitionWidget(ls *widget.List, definedVars *kwp2000.VarDefinitionL
 	})
 	vd.symbolSigned.Disable()
 
+	vd.symbolMask = &widget.Entry{
+		PlaceHolder: "Mask",
+		OnChanged: func(s string) {
+			var mask uint16
+			if s != "" {
+				v, err := strconv.ParseUint(s, 16, 16)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+				mask = uint16(v)
+			}
+			if definedVars.GetPos(vd.pos).Mask != mask {
+				definedVars.SetMask(vd.pos, mask)
+			}
+		},
+	}
+
 	vd.symbolCorrectionfactor = &widget.Entry{
 		OnChanged: func(s string) {
 			if definedVars.GetPos(vd.pos).Correctionfactor != s {
@@ -100,6 +119,7 @@ func NewVarDefinitionWidget(ls *widget.List, definedVars *kwp2000.VarDefinitionL
 			MinWidth(50, vd.symbolNumber),
 			MinWidth(40, vd.symbolType),
 			MinWidth(80, vd.symbolSigned),
+			MinWidth(50, vd.symbolMask),
 			MinWidth(50, vd.symbolCorrectionfactor),
 			MinWidth(130, vd.symbolGroup),
 			MinWidth(90, vd.symbolDeleteBTN),
@@ -145,6 +165,7 @@ func (wb *VarDefinitionWidget) Update(pos int, sym *kwp2000.VarDefinition) {
 	wb.symbolNumber.SetText(strconv.Itoa(sym.Value))
 	wb.symbolType.SetText(fmt.Sprintf("%X", sym.Type))
 	wb.symbolSigned.SetChecked(sym.Type&kwp2000.SIGNED != 0)
+	wb.SetMask(sym.Mask)
 	wb.symbolGroup.SetText(sym.Group)
 	wb.symbolCorrectionfactor.SetText(sym.Correctionfactor)
 	sym.SetWidget(wb)
@@ -156,6 +177,7 @@ func (wb *VarDefinitionWidget) Disable() {
 	wb.symbolNumber.Disable()
 	wb.symbolType.Disable()
 	wb.symbolSigned.Disable()
+	wb.symbolMask.Disable()
 	wb.symbolGroup.Disable()
 	wb.symbolCorrectionfactor.Disable()
 	wb.symbolDeleteBTN.Disable()
@@ -167,6 +189,7 @@ func (wb *VarDefinitionWidget) Enable() {
 	wb.symbolNumber.Enable()
 	wb.symbolType.Enable()
 	wb.symbolSigned.Enable()
+	wb.symbolMask.Enable()
 	wb.symbolGroup.Enable()
 	wb.symbolCorrectionfactor.Enable()
 	wb.symbolDeleteBTN.Enable()
@@ -193,6 +216,14 @@ func (wb *VarDefinitionWidget) SetType(t uint8) {
 	wb.symbolSigned.SetChecked(t&kwp2000.SIGNED != 0)
 }
 
+func (wb *VarDefinitionWidget) SetMask(mask uint16) {
+	if mask == 0 {
+		wb.symbolMask.SetText("")
+		return
+	}
+	wb.symbolMask.SetText(fmt.Sprintf("%X", mask))
+}
+
 func (wb *VarDefinitionWidget) MinSize() fyne.Size {
 	return wb.objects[0].(*fyne.Container).MinSize()
 }
@@ -2,7 +2,10 @@ package widgets
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -10,6 +13,9 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/roffe/gocan"
 	"github.com/roffe/gocan/adapter"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+	"github.com/roffe/t7logger/pkg/t5"
+	"github.com/roffe/t7logger/pkg/t8"
 	"go.bug.st/serial/enumerator"
 )
 
@@ -24,6 +30,10 @@ type CanSettingsWidget struct {
 	portSelector    *widget.Select
 	speedSelector   *widget.Select
 	refreshBtn      *widget.Button
+	// KWP settings for slow adapters and gateways, defaults are used when empty
+	timeoutEntry     *widget.Entry
+	readTimeoutEntry *widget.Entry
+	requestIDEntry   *widget.Entry
 }
 
 func NewCanSettingsWidget(app fyne.App) *CanSettingsWidget {
@@ -60,6 +70,22 @@ func NewCanSettingsWidget(app fyne.App) *CanSettingsWidget {
 		csw.portSelector.Refresh()
 	})
 
+	csw.timeoutEntry = widget.NewEntry()
+	csw.timeoutEntry.SetPlaceHolder("Timeout 250 ms")
+	csw.timeoutEntry.OnChanged = func(s string) {
+		app.Preferences().SetString(prefsKWPTimeout, s)
+	}
+	csw.readTimeoutEntry = widget.NewEntry()
+	csw.readTimeoutEntry.SetPlaceHolder("Read timeout 50 ms")
+	csw.readTimeoutEntry.OnChanged = func(s string) {
+		app.Preferences().SetString(prefsKWPReadTimeout, s)
+	}
+	csw.requestIDEntry = widget.NewEntry()
+	csw.requestIDEntry.SetPlaceHolder(fmt.Sprintf("Request id 0x%X", kwp2000.REQ_MSG_ID))
+	csw.requestIDEntry.OnChanged = func(s string) {
+		app.Preferences().SetString(prefsKWPRequestID, s)
+	}
+
 	csw.objects = []fyne.CanvasObject{
 		container.NewVBox(
 			container.NewBorder(
@@ -83,6 +109,17 @@ func NewCanSettingsWidget(app fyne.App) *CanSettingsWidget {
 				nil,
 				csw.speedSelector,
 			),
+			container.NewBorder(
+				nil,
+				nil,
+				MinWidth(100, widget.NewLabel("KWP")),
+				nil,
+				container.NewGridWithColumns(3,
+					csw.timeoutEntry,
+					csw.readTimeoutEntry,
+					csw.requestIDEntry,
+				),
+			),
 		),
 	}
 	csw.loadPrefs()
@@ -95,6 +132,9 @@ func (c *CanSettingsWidget) Disable() {
 	c.speedSelector.Disable()
 	c.debugCheckbox.Disable()
 	c.refreshBtn.Disable()
+	c.timeoutEntry.Disable()
+	c.readTimeoutEntry.Disable()
+	c.requestIDEntry.Disable()
 }
 
 func (c *CanSettingsWidget) Enable() {
@@ -103,6 +143,9 @@ func (c *CanSettingsWidget) Enable() {
 	c.speedSelector.Enable()
 	c.debugCheckbox.Enable()
 	c.refreshBtn.Enable()
+	c.timeoutEntry.Enable()
+	c.readTimeoutEntry.Enable()
+	c.requestIDEntry.Enable()
 }
 
 const (
@@ -110,6 +153,10 @@ const (
 	prefsPort    = "port"
 	prefsSpeed   = "speed"
 	prefsDebug   = "debug"
+
+	prefsKWPTimeout     = "kwpTimeout"
+	prefsKWPReadTimeout = "kwpReadTimeout"
+	prefsKWPRequestID   = "kwpRequestID"
 )
 
 func (cs *CanSettingsWidget) loadPrefs() {
@@ -125,9 +172,52 @@ func (cs *CanSettingsWidget) loadPrefs() {
 	if debug := cs.app.Preferences().Bool(prefsDebug); debug {
 		cs.debugCheckbox.SetChecked(debug)
 	}
+	cs.timeoutEntry.SetText(cs.app.Preferences().String(prefsKWPTimeout))
+	cs.readTimeoutEntry.SetText(cs.app.Preferences().String(prefsKWPReadTimeout))
+	cs.requestIDEntry.SetText(cs.app.Preferences().String(prefsKWPRequestID))
 }
 
-func (cs *CanSettingsWidget) GetAdapter(logger func(string)) (gocan.Adapter, error) {
+// KWPOptions returns the KWP client options for the timeouts in milliseconds and the hex request id
+// entered, empty fields keep the defaults
+func (cs *CanSettingsWidget) KWPOptions() ([]kwp2000.Option, error) {
+	var opts []kwp2000.Option
+	if s := strings.TrimSpace(cs.timeoutEntry.Text); s != "" {
+		ms, err := strconv.Atoi(s)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("invalid KWP timeout %q, expected milliseconds", s)
+		}
+		opts = append(opts, kwp2000.WithTimeout(time.Duration(ms)*time.Millisecond))
+	}
+	if s := strings.TrimSpace(cs.readTimeoutEntry.Text); s != "" {
+		ms, err := strconv.Atoi(s)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("invalid KWP read timeout %q, expected milliseconds", s)
+		}
+		opts = append(opts, kwp2000.WithReadDataTimeout(time.Duration(ms)*time.Millisecond))
+	}
+	if s := strings.TrimSpace(cs.requestIDEntry.Text); s != "" {
+		id, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 11)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KWP request id %q, expected a hex CAN id", s)
+		}
+		opts = append(opts, kwp2000.WithRequestID(uint32(id)))
+	}
+	return opts, nil
+}
+
+// GetAdapter creates the selected adapter with the bus speed and filter of the ECU type
+func (cs *CanSettingsWidget) GetAdapter(ecu string, logger func(string)) (gocan.Adapter, error) {
+	canRate, canFilter := canConfig(ecu)
+	return cs.newAdapter(canRate, canFilter, logger)
+}
+
+// GetListenAdapter creates the selected adapter at the bus speed of the ECU type receiving only ids
+func (cs *CanSettingsWidget) GetListenAdapter(ecu string, ids []uint32, logger func(string)) (gocan.Adapter, error) {
+	canRate, _ := canConfig(ecu)
+	return cs.newAdapter(canRate, ids, logger)
+}
+
+func (cs *CanSettingsWidget) newAdapter(canRate float64, canFilter []uint32, logger func(string)) (gocan.Adapter, error) {
 	baudrate, err := strconv.Atoi(cs.speedSelector.Selected)
 
 	if cs.adapterSelector.Selected == "" {
@@ -154,8 +244,8 @@ func (cs *CanSettingsWidget) GetAdapter(logger func(string)) (gocan.Adapter, err
 		&gocan.AdapterConfig{
 			Port:         cs.portSelector.Selected,
 			PortBaudrate: baudrate,
-			CANRate:      500,
-			CANFilter:    []uint32{0x238, 0x258, 0x270},
+			CANRate:      canRate,
+			CANFilter:    canFilter,
 			OnMessage:    logger,
 			OnError: func(err error) {
 				logger(err.Error())
@@ -165,6 +255,18 @@ func (cs *CanSettingsWidget) GetAdapter(logger func(string)) (gocan.Adapter, err
 
 }
 
+// canConfig returns the bus speed and the ids the ECU answers on
+func canConfig(ecu string) (float64, []uint32) {
+	switch ecu {
+	case "T5":
+		return t5.CANRate, []uint32{t5.RESP_MSG_ID}
+	case "T8":
+		return 500, []uint32{t8.RESP_MSG_ID, t8.UUDT_RESP_MSG_ID}
+	default:
+		return 500, []uint32{0x238, 0x258, 0x270}
+	}
+}
+
 func (cs *CanSettingsWidget) MinSize() fyne.Size {
 	return cs.objects[0].MinSize()
 }
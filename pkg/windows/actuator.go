@@ -0,0 +1,124 @@
+package windows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/t7logger/pkg/ecu"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+func (mw *MainWindow) newActuatorBtn() {
+	mw.actuatorBtn = widget.NewButtonWithIcon("Actuator test", theme.MediaPlayIcon(), func() {
+		if mw.actuatorWindow != nil {
+			mw.actuatorWindow.RequestFocus()
+			return
+		}
+		mw.actuatorWindow = mw.newActuatorWindow()
+		mw.actuatorWindow.Show()
+	})
+}
+
+// newActuatorWindow controls a single output, the local id is entered by the user as the
+// Trionic 7 output ids are not documented
+func (mw *MainWindow) newActuatorWindow() fyne.Window {
+	w := mw.app.NewWindow("Actuator test")
+	var cancel context.CancelFunc
+
+	idEntry := widget.NewEntry()
+	idEntry.SetPlaceHolder("Local id in hex, e.g. 0x01")
+
+	durationLabel := widget.NewLabel("")
+	duration := widget.NewSlider(1, kwp2000.MaxActuatorDuration.Seconds())
+	duration.OnChanged = func(f float64) {
+		durationLabel.SetText(fmt.Sprintf("%.0f s", f))
+	}
+	duration.SetValue(5)
+
+	dutyLabel := widget.NewLabel("")
+	duty := widget.NewSlider(0, 100)
+	duty.OnChanged = func(f float64) {
+		dutyLabel.SetText(fmt.Sprintf("%.0f %%", f))
+	}
+	duty.SetValue(50)
+	pwmCheck := widget.NewCheck("PWM output", nil)
+
+	var startBtn *widget.Button
+	stopBtn := widget.NewButtonWithIcon("Stop", theme.MediaStopIcon(), func() {
+		if cancel != nil {
+			cancel()
+		}
+	})
+	stopBtn.Disable()
+
+	startBtn = widget.NewButtonWithIcon("Start", theme.MediaPlayIcon(), func() {
+		if mw.loggingRunning {
+			dialog.ShowError(errors.New("Stop logging before testing actuators"), w) //lint:ignore ST1005 ignore error
+			return
+		}
+		id, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(idEntry.Text)), "0x"), 16, 8)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("invalid local id %q: %w", idEntry.Text, err), w)
+			return
+		}
+		a := kwp2000.Actuator{Name: fmt.Sprintf("Output 0x%02X", id), ID: byte(id), PWM: pwmCheck.Checked}
+		state := byte(1)
+		if a.PWM {
+			state = byte(duty.Value)
+		}
+		opts, err := mw.canSettings.KWPOptions()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		device, err := mw.canSettings.GetAdapter("T7", mw.Log)
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		startBtn.Disable()
+		stopBtn.Enable()
+		go func() {
+			defer func() {
+				cancel()
+				stopBtn.Disable()
+				startBtn.Enable()
+			}()
+			if err := ecu.TestActuator(ctx, device, a, state, time.Duration(duration.Value)*time.Second, mw.Log, opts...); err != nil {
+				mw.Log(fmt.Sprintf("%s test failed: %v", a.Name, err))
+				dialog.ShowError(ecuError(err), w)
+			}
+		}()
+	})
+
+	w.SetContent(container.NewVBox(
+		widget.NewLabel("The output ids are not documented, only control outputs you know the local id of"),
+		widget.NewForm(
+			widget.NewFormItem("Local id", idEntry),
+			widget.NewFormItem("Duration", container.NewBorder(nil, nil, nil, durationLabel, duration)),
+			widget.NewFormItem("Type", pwmCheck),
+			widget.NewFormItem("Duty cycle (PWM)", container.NewBorder(nil, nil, nil, dutyLabel, duty)),
+		),
+		container.NewGridWithColumns(2, startBtn, stopBtn),
+	))
+	w.Resize(fyne.NewSize(450, 0))
+	// never leave an output activated when the window goes away
+	w.SetOnClosed(func() {
+		if cancel != nil {
+			cancel()
+		}
+		mw.actuatorWindow = nil
+	})
+	return w
+}
@@ -10,6 +10,8 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	xwidget "fyne.io/x/fyne/widget"
+	"github.com/roffe/gocan"
+	"github.com/roffe/t7logger/pkg/broadcast"
 	"github.com/roffe/t7logger/pkg/datalogger"
 	"github.com/roffe/t7logger/pkg/widgets"
 )
@@ -23,7 +25,23 @@ func (mw *MainWindow) newLogBtn() {
 			return
 		}
 		if !mw.loggingRunning {
-			device, err := mw.canSettings.GetAdapter(mw.Log)
+			kwpOpts, err := mw.canSettings.KWPOptions()
+			if err != nil {
+				dialog.ShowError(err, mw)
+				return
+			}
+			var device gocan.Adapter
+			if mw.passiveCheck.Checked {
+				// only the broadcast ids are received, nothing is sent
+				signals, serr := datalogger.PassiveSignals(mw.dbcSignals)
+				if serr != nil {
+					dialog.ShowError(serr, mw)
+					return
+				}
+				device, err = mw.canSettings.GetListenAdapter(mw.ecuSelect.Selected, broadcast.IDs(signals), mw.Log)
+			} else {
+				device, err = mw.canSettings.GetAdapter(mw.ecuSelect.Selected, mw.Log)
+			}
 			if err != nil {
 				dialog.ShowError(err, mw)
 				return
@@ -35,10 +53,15 @@ func (mw *MainWindow) newLogBtn() {
 				Variables:             mw.vars.Get(),
 				Freq:                  int(mw.freqSlider.Value),
 				OnMessage:             mw.Log,
+				OnIdentification:      mw.setECUInfo,
 				CaptureCounter:        mw.captureCounter,
 				ErrorCounter:          mw.errorCounter,
 				ErrorPerSecondCounter: mw.errorPerSecondCounter,
 				Sink:                  mw.sinkManager,
+				Passive:               mw.passiveCheck.Checked,
+				Signals:               mw.dbcSignals,
+				LogFormat:             mw.logFormat(),
+				KWPOptions:            kwpOpts,
 			})
 			if err != nil {
 				dialog.ShowError(err, mw)
@@ -0,0 +1,205 @@
+package windows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/t7logger/pkg/ecu"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+	sdialog "github.com/sqweek/dialog"
+)
+
+func (mw *MainWindow) newDTCPanel() {
+	mw.dtcList = widget.NewListWithData(
+		mw.dtcData,
+		func() fyne.CanvasObject {
+			return &widget.Label{
+				Alignment: fyne.TextAlignLeading,
+				TextStyle: fyne.TextStyle{Monospace: true},
+			}
+		},
+		func(item binding.DataItem, obj fyne.CanvasObject) {
+			txt, err := item.(binding.String).Get()
+			if err != nil {
+				mw.Log(err.Error())
+				return
+			}
+			obj.(*widget.Label).SetText(txt)
+		},
+	)
+
+	mw.readDTCBtn = widget.NewButtonWithIcon("Read DTC", theme.SearchIcon(), func() {
+		mw.progressBar.Start()
+		mw.disableBtns()
+		defer mw.enableBtns()
+		defer mw.progressBar.Stop()
+		if err := mw.readDTC(); err != nil {
+			dialog.ShowError(ecuError(err), mw)
+		}
+	})
+
+	mw.clearDTCBtn = widget.NewButtonWithIcon("Clear DTC", theme.DeleteIcon(), func() {
+		dialog.ShowConfirm("Clear DTC", "Clear all stored trouble codes?", func(ok bool) {
+			if !ok {
+				return
+			}
+			mw.progressBar.Start()
+			mw.disableBtns()
+			defer mw.enableBtns()
+			defer mw.progressBar.Stop()
+			if err := mw.clearDTC(); err != nil {
+				dialog.ShowError(ecuError(err), mw)
+				return
+			}
+			if err := mw.readDTC(); err != nil {
+				dialog.ShowError(ecuError(err), mw)
+			}
+		}, mw)
+	})
+
+	mw.freezeFrameBtn = widget.NewButtonWithIcon("Freeze frames", theme.MediaPauseIcon(), func() {
+		mw.progressBar.Start()
+		mw.disableBtns()
+		defer mw.enableBtns()
+		defer mw.progressBar.Stop()
+		if err := mw.readFreezeFrames(); err != nil {
+			dialog.ShowError(ecuError(err), mw)
+		}
+	})
+
+	mw.exportFreezeFrameBtn = widget.NewButtonWithIcon("Export", theme.DocumentSaveIcon(), func() {
+		if len(mw.freezeFrames) == 0 {
+			dialog.ShowError(errors.New("Read freeze frames first"), mw) //lint:ignore ST1005 ignore error
+			return
+		}
+		filename, err := sdialog.File().Filter("*.csv", "csv").Save()
+		if err != nil {
+			if err.Error() == "Cancelled" {
+				return
+			}
+			dialog.ShowError(err, mw)
+			return
+		}
+		if err := mw.exportFreezeFrames(filename); err != nil {
+			dialog.ShowError(err, mw)
+		}
+	})
+}
+
+func (mw *MainWindow) dtcPanel() fyne.CanvasObject {
+	return container.NewBorder(
+		container.NewGridWithColumns(5,
+			mw.readDTCBtn,
+			mw.clearDTCBtn,
+			mw.freezeFrameBtn,
+			mw.exportFreezeFrameBtn,
+			mw.actuatorBtn,
+		),
+		nil,
+		nil,
+		nil,
+		mw.dtcList,
+	)
+}
+
+func (mw *MainWindow) readDTC() error {
+	opts, err := mw.canSettings.KWPOptions()
+	if err != nil {
+		return err
+	}
+	device, err := mw.canSettings.GetAdapter("T7", mw.Log)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dtcs, err := ecu.ReadDTC(ctx, device, opts...)
+	if err != nil {
+		return err
+	}
+	var rows []string
+	for _, dtc := range dtcs {
+		rows = append(rows, fmt.Sprintf("%s %-14s %s", dtc.String(), dtc.StatusString(), dtc.Description()))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, "No trouble codes stored")
+	}
+	mw.Log(fmt.Sprintf("Read %d trouble codes", len(dtcs)))
+	return mw.dtcData.Set(rows)
+}
+
+func (mw *MainWindow) clearDTC() error {
+	opts, err := mw.canSettings.KWPOptions()
+	if err != nil {
+		return err
+	}
+	device, err := mw.canSettings.GetAdapter("T7", mw.Log)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := ecu.ClearDTC(ctx, device, opts...); err != nil {
+		return err
+	}
+	mw.Log("Cleared trouble codes")
+	return nil
+}
+
+func (mw *MainWindow) readFreezeFrames() error {
+	opts, err := mw.canSettings.KWPOptions()
+	if err != nil {
+		return err
+	}
+	device, err := mw.canSettings.GetAdapter("T7", mw.Log)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	frames, err := ecu.ReadFreezeFrames(ctx, device, opts...)
+	if err != nil {
+		return err
+	}
+	var rows []string
+	for _, frame := range frames {
+		rows = append(rows, fmt.Sprintf("%s %-14s %s", frame.DTC.String(), frame.DTC.StatusString(), frame.DTC.Description()))
+		values, unknown := frame.Decode(mw.symbolMap)
+		for _, v := range values {
+			if v != nil {
+				rows = append(rows, "    "+v.String())
+			}
+		}
+		if len(unknown) > 0 {
+			rows = append(rows, fmt.Sprintf("    %X", frame.Data), "    unknown: "+strings.Join(unknown, ", "))
+		}
+	}
+	if len(rows) == 0 {
+		rows = append(rows, "No freeze frames stored")
+	}
+	mw.freezeFrames = frames
+	mw.Log(fmt.Sprintf("Read %d freeze frames", len(frames)))
+	return mw.dtcData.Set(rows)
+}
+
+func (mw *MainWindow) exportFreezeFrames(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create freeze frame file: %w", err)
+	}
+	defer f.Close()
+	if err := kwp2000.WriteFreezeFrames(f, mw.freezeFrames, mw.symbolMap); err != nil {
+		return fmt.Errorf("failed to write freeze frames: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package windows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/t7logger/pkg/ecu"
+	sdialog "github.com/sqweek/dialog"
+)
+
+func (mw *MainWindow) newDumpFlashBtn() {
+	mw.dumpFlashBtn = widget.NewButtonWithIcon("Read ECU to file", theme.DownloadIcon(), func() {
+		if mw.loggingRunning {
+			dialog.ShowError(errors.New("Stop logging before reading the flash"), mw) //lint:ignore ST1005 ignore error
+			return
+		}
+		filename, err := sdialog.File().Filter("Binary file", "bin").Save()
+		if err != nil {
+			if err.Error() == "Cancelled" {
+				return
+			}
+			dialog.ShowError(err, mw)
+			return
+		}
+		opts, err := mw.canSettings.KWPOptions()
+		if err != nil {
+			dialog.ShowError(err, mw)
+			return
+		}
+		device, err := mw.canSettings.GetAdapter("T7", mw.Log)
+		if err != nil {
+			dialog.ShowError(err, mw)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		bar := widget.NewProgressBar()
+		bar.Max = ecu.FlashSize
+		status := widget.NewLabel("Connecting...")
+		d := dialog.NewCustom("Reading flash", "Cancel", container.NewVBox(bar, status), mw)
+		d.SetOnClosed(cancel)
+		d.Show()
+
+		mw.disableBtns()
+		go func() {
+			defer mw.enableBtns()
+			defer d.Hide()
+			err := ecu.DumpFlash(ctx, device, filename, mw.Log, func(p ecu.FlashProgress) {
+				bar.SetValue(float64(p.Done))
+				status.SetText(fmt.Sprintf("%d / %d KB, %s left", p.Done/1024, p.Total/1024, p.ETA.Round(time.Second)))
+			}, opts...)
+			if err != nil && ctx.Err() == nil {
+				dialog.ShowError(ecuError(err), mw)
+			}
+		}()
+	})
+}
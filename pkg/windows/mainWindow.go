@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -16,10 +17,13 @@ import (
 	"fyne.io/fyne/v2/widget"
 	xwidget "fyne.io/x/fyne/widget"
 	"github.com/roffe/t7logger/dashboard"
+	"github.com/roffe/t7logger/pkg/broadcast"
 	"github.com/roffe/t7logger/pkg/datalogger"
+	"github.com/roffe/t7logger/pkg/dbc"
 	"github.com/roffe/t7logger/pkg/debug"
 	"github.com/roffe/t7logger/pkg/ecu"
 	"github.com/roffe/t7logger/pkg/kwp2000"
+	"github.com/roffe/t7logger/pkg/mdf"
 	"github.com/roffe/t7logger/pkg/sink"
 	"github.com/roffe/t7logger/pkg/symbol"
 	"github.com/roffe/t7logger/pkg/widgets"
@@ -30,6 +34,11 @@ import (
 const (
 	prefsLastConfig  = "lastConfig"
 	prefsSelectedECU = "lastECU"
+	prefsKeyMethods  = "securityKeyMethods"
+	prefsPassive     = "passive"
+	prefsDBCFile     = "dbcFile"
+	prefsLogFormat   = "logFormat"
+	prefsAlsoMSL     = "alsoMSL"
 )
 
 type MainWindow struct {
@@ -37,6 +46,8 @@ type MainWindow struct {
 	app fyne.App
 
 	symbolMap map[string]*kwp2000.VarDefinition
+	// symbols is the symbol table the symbol map was loaded from, used to look up addresses
+	symbols []*symbol.Symbol
 
 	symbolLookup     *xwidget.CompletionEntry
 	symbolConfigList *widget.List
@@ -44,9 +55,23 @@ type MainWindow struct {
 	output     *widget.List
 	outputData binding.StringList
 
+	dtcList      *widget.List
+	dtcData      binding.StringList
+	freezeFrames []*kwp2000.FreezeFrame
+
 	canSettings *widgets.CanSettingsWidget
 
 	ecuSelect *widget.Select
+	// passiveCheck selects logging the broadcast values without a diagnostic session
+	passiveCheck *widget.Check
+	// dbcSignals are decoded in passive mode instead of the known broadcast signals when loaded
+	dbcSignals []*broadcast.Signal
+	ecuInfo    *widget.Label
+
+	logFormatSelect *widget.Select
+	// mslCheck writes a MegaLogViewer log alongside the selected format
+	mslCheck      *widget.Check
+	convertLogBtn *widget.Button
 
 	addSymbolBtn       *widget.Button
 	logBtn             *widget.Button
@@ -54,6 +79,17 @@ type MainWindow struct {
 	loadSymbolsEcuBtn  *widget.Button
 	loadSymbolsFileBtn *widget.Button
 	dashboardBtn       *widget.Button
+	writeValueBtn      *widget.Button
+	actuatorBtn        *widget.Button
+	dumpFlashBtn       *widget.Button
+	loadDBCBtn         *widget.Button
+
+	actuatorWindow fyne.Window
+
+	readDTCBtn           *widget.Button
+	clearDTCBtn          *widget.Button
+	freezeFrameBtn       *widget.Button
+	exportFreezeFrameBtn *widget.Button
 
 	loadConfigBtn  *widget.Button
 	saveConfigBtn  *widget.Button
@@ -88,10 +124,19 @@ func (mw *MainWindow) disableBtns() {
 	mw.syncSymbolsBtn.Disable()
 	mw.loadSymbolsFileBtn.Disable()
 	mw.loadSymbolsEcuBtn.Disable()
+	mw.dumpFlashBtn.Disable()
+	mw.actuatorBtn.Disable()
+	mw.loadDBCBtn.Disable()
 	if !mw.loggingRunning {
 		mw.logBtn.Disable()
+		// values are written through the session while logging
+		mw.writeValueBtn.Disable()
 	}
 	mw.mockBtn.Disable()
+	mw.readDTCBtn.Disable()
+	mw.clearDTCBtn.Disable()
+	mw.freezeFrameBtn.Disable()
+	mw.exportFreezeFrameBtn.Disable()
 	mw.canSettings.Disable()
 	for _, v := range mw.vars.Get() {
 		v.Widget.(*widgets.VarDefinitionWidget).Disable()
@@ -105,8 +150,16 @@ func (mw *MainWindow) enableBtns() {
 	mw.syncSymbolsBtn.Enable()
 	mw.loadSymbolsFileBtn.Enable()
 	mw.loadSymbolsEcuBtn.Enable()
+	mw.dumpFlashBtn.Enable()
+	mw.actuatorBtn.Enable()
+	mw.loadDBCBtn.Enable()
 	mw.logBtn.Enable()
+	mw.writeValueBtn.Enable()
 	mw.mockBtn.Enable()
+	mw.readDTCBtn.Enable()
+	mw.clearDTCBtn.Enable()
+	mw.freezeFrameBtn.Enable()
+	mw.exportFreezeFrameBtn.Enable()
 	mw.canSettings.Enable()
 	for _, v := range mw.vars.Get() {
 		v.Widget.(*widgets.VarDefinitionWidget).Enable()
@@ -114,11 +167,13 @@ func (mw *MainWindow) enableBtns() {
 }
 
 func NewMainWindow(a fyne.App, singMgr *sink.Manager, vars *kwp2000.VarDefinitionList) *MainWindow {
+	mdf.ToolVersion = a.Metadata().Version
 	mw := &MainWindow{
 		Window:                a.NewWindow("TrionicLogger"),
 		app:                   a,
 		symbolMap:             make(map[string]*kwp2000.VarDefinition),
 		outputData:            binding.NewStringList(),
+		dtcData:               binding.NewStringList(),
 		canSettings:           widgets.NewCanSettingsWidget(a),
 		captureCounter:        binding.NewInt(),
 		errorCounter:          binding.NewInt(),
@@ -167,7 +222,7 @@ func NewMainWindow(a fyne.App, singMgr *sink.Manager, vars *kwp2000.VarDefinitio
 		defer mw.enableBtns()
 		defer mw.progressBar.Stop()
 		if err := mw.loadSymbolsFromECU(); err != nil {
-			dialog.ShowError(err, mw)
+			dialog.ShowError(ecuError(err), mw)
 			return
 		}
 	})
@@ -233,6 +288,10 @@ func NewMainWindow(a fyne.App, singMgr *sink.Manager, vars *kwp2000.VarDefinitio
 	mw.newSymbolnameTypeahead()
 	mw.newLogBtn()
 	mw.newMockBtn()
+	mw.newDTCPanel()
+	mw.newWriteValueBtn()
+	mw.newActuatorBtn()
+	mw.newDumpFlashBtn()
 
 	mw.capturedCounterLabel = &widget.Label{
 		Alignment: fyne.TextAlignLeading,
@@ -268,10 +327,58 @@ func NewMainWindow(a fyne.App, singMgr *sink.Manager, vars *kwp2000.VarDefinitio
 		}
 	}))
 
-	mw.ecuSelect = widget.NewSelect([]string{"T7", "T8"}, func(s string) {
+	mw.ecuInfo = &widget.Label{
+		Text:     "Not connected",
+		Wrapping: fyne.TextWrapWord,
+	}
+
+	mw.ecuSelect = widget.NewSelect([]string{"T5", "T7", "T8"}, func(s string) {
 		mw.app.Preferences().SetString(prefsSelectedECU, s)
 	})
 
+	mw.passiveCheck = widget.NewCheck("Passive", func(b bool) {
+		mw.app.Preferences().SetBool(prefsPassive, b)
+	})
+
+	mw.logFormatSelect = widget.NewSelect(datalogger.LogFormats, func(s string) {
+		mw.app.Preferences().SetString(prefsLogFormat, s)
+	})
+
+	mw.mslCheck = widget.NewCheck("Also .msl", func(b bool) {
+		mw.app.Preferences().SetBool(prefsAlsoMSL, b)
+	})
+
+	mw.convertLogBtn = widget.NewButtonWithIcon("Convert .t7l", theme.DocumentSaveIcon(), func() {
+		filename, err := sdialog.File().Filter("*.t7l", "t7l").Load()
+		if err != nil {
+			if err.Error() == "Cancelled" {
+				return
+			}
+			dialog.ShowError(err, mw)
+			return
+		}
+		if err := mw.convertLog(filename); err != nil {
+			dialog.ShowError(err, mw)
+			return
+		}
+	})
+
+	mw.loadDBCBtn = widget.NewButtonWithIcon("DBC", theme.FileIcon(), func() {
+		filename, err := sdialog.File().Filter("*.dbc", "dbc").Load()
+		if err != nil {
+			if err.Error() == "Cancelled" {
+				return
+			}
+			dialog.ShowError(err, mw)
+			return
+		}
+		if err := mw.loadDBC(filename); err != nil {
+			dialog.ShowError(err, mw)
+			return
+		}
+		mw.app.Preferences().SetString(prefsDBCFile, filename)
+	})
+
 	mw.loadPrefs()
 	mw.setTitle("No symbols loaded")
 
@@ -286,6 +393,88 @@ func (mw *MainWindow) loadPrefs() {
 	if ecu := mw.app.Preferences().StringWithFallback(prefsSelectedECU, "T7"); ecu != "" {
 		mw.ecuSelect.SetSelected(ecu)
 	}
+	mw.passiveCheck.SetChecked(mw.app.Preferences().Bool(prefsPassive))
+	mw.logFormatSelect.SetSelected(mw.app.Preferences().StringWithFallback(prefsLogFormat, datalogger.LogFormats[0]))
+	mw.mslCheck.SetChecked(mw.app.Preferences().Bool(prefsAlsoMSL))
+	if filename := mw.app.Preferences().String(prefsDBCFile); filename != "" {
+		if err := mw.loadDBC(filename); err != nil {
+			mw.Log(err.Error())
+		}
+	}
+
+	// remember which key algorithm each software version accepted between runs
+	if b := mw.app.Preferences().String(prefsKeyMethods); b != "" {
+		known := make(map[string]string)
+		if err := json.Unmarshal([]byte(b), &known); err == nil {
+			kwp2000.DefaultKeyRegistry.SetKnown(known)
+		}
+	}
+	kwp2000.DefaultKeyRegistry.OnLearn(func(softwareVersion, algorithm string) {
+		mw.Log(fmt.Sprintf("Security access for %s granted using %s", softwareVersion, algorithm))
+		if b, err := json.Marshal(kwp2000.DefaultKeyRegistry.Known()); err == nil {
+			mw.app.Preferences().SetString(prefsKeyMethods, string(b))
+		}
+	})
+}
+
+// logFormat returns the formats to log in, comma separated
+func (mw *MainWindow) logFormat() string {
+	format := mw.logFormatSelect.Selected
+	if mw.mslCheck.Checked && format != "msl" {
+		format += ",msl"
+	}
+	return format
+}
+
+// convertLog converts a .t7l log to a .msl next to it, units are taken from the symbols and the config
+func (mw *MainWindow) convertLog(filename string) error {
+	var vars []*kwp2000.VarDefinition
+	for _, v := range mw.symbolMap {
+		vars = append(vars, v)
+	}
+	units := datalogger.ChannelUnits(vars)
+	for k, v := range datalogger.ChannelUnits(mw.vars.Get()) {
+		units[k] = v
+	}
+
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	outname := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".msl"
+	out, err := os.Create(outname)
+	if err != nil {
+		return err
+	}
+	if err := datalogger.ConvertT7L(in, out, units); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to convert %s: %w", filepath.Base(filename), err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	mw.Log(fmt.Sprintf("Converted %s to %s", filepath.Base(filename), filepath.Base(outname)))
+	return nil
+}
+
+// loadDBC reads the signals decoded in passive mode from a DBC file
+func (mw *MainWindow) loadDBC(filename string) error {
+	f, err := dbc.Load(filename)
+	if err != nil {
+		return err
+	}
+	signals := f.Signals()
+	if len(signals) == 0 {
+		return fmt.Errorf("no signals found in %s", filepath.Base(filename))
+	}
+	mw.dbcSignals = signals
+	msg := fmt.Sprintf("Loaded %d signals in %d messages from %s", len(signals), len(f.Messages), filepath.Base(filename))
+	if f.Skipped > 0 {
+		msg += fmt.Sprintf(", %d multiplexed signals skipped", f.Skipped)
+	}
+	mw.Log(msg)
+	return nil
 }
 
 func (mw *MainWindow) setTitle(str string) {
@@ -307,6 +496,7 @@ func (mw *MainWindow) Layout() fyne.CanvasObject {
 						mw.addSymbolBtn,
 						mw.loadSymbolsFileBtn,
 						mw.loadSymbolsEcuBtn,
+						mw.dumpFlashBtn,
 					),
 					mw.symbolLookup,
 				),
@@ -346,11 +536,12 @@ func (mw *MainWindow) Layout() fyne.CanvasObject {
 				),
 			),
 			container.NewVBox(
-				container.NewGridWithColumns(4,
+				container.NewGridWithColumns(5,
 					mw.loadConfigBtn,
 					mw.syncSymbolsBtn,
 					mw.saveConfigBtn,
 					mw.dashboardBtn,
+					mw.writeValueBtn,
 				),
 			),
 			nil,
@@ -365,9 +556,29 @@ func (mw *MainWindow) Layout() fyne.CanvasObject {
 					nil,
 					nil,
 					widgets.MinWidth(100, widget.NewLabel("Select ECU")),
-					nil,
+					container.NewHBox(
+						mw.passiveCheck,
+						mw.loadDBCBtn,
+					),
 					mw.ecuSelect,
 				),
+				container.NewBorder(
+					nil,
+					nil,
+					widgets.MinWidth(100, widget.NewLabel("ECU")),
+					nil,
+					mw.ecuInfo,
+				),
+				container.NewBorder(
+					nil,
+					nil,
+					widgets.MinWidth(100, widget.NewLabel("Log format")),
+					container.NewHBox(
+						mw.mslCheck,
+						mw.convertLogBtn,
+					),
+					mw.logFormatSelect,
+				),
 				mw.canSettings,
 				mw.logBtn,
 				mw.progressBar,
@@ -375,7 +586,10 @@ func (mw *MainWindow) Layout() fyne.CanvasObject {
 			Trailing: &container.Split{
 				Offset:     1,
 				Horizontal: false,
-				Leading:    mw.output,
+				Leading: container.NewAppTabs(
+					container.NewTabItem("Log", mw.output),
+					container.NewTabItem("DTC", mw.dtcPanel()),
+				),
 				Trailing: container.NewVBox(
 					mw.mockBtn,
 					mw.freqSlider,
@@ -393,13 +607,17 @@ func (mw *MainWindow) Layout() fyne.CanvasObject {
 }
 
 func (mw *MainWindow) loadSymbolsFromECU() error {
-	device, err := mw.canSettings.GetAdapter(mw.Log)
+	opts, err := mw.canSettings.KWPOptions()
+	if err != nil {
+		return err
+	}
+	device, err := mw.canSettings.GetAdapter("T7", mw.Log)
 	if err != nil {
 		return err
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
-	symbols, err := ecu.GetSymbols(ctx, device, mw.Log)
+	symbols, err := ecu.GetSymbols(ctx, device, mw.Log, opts...)
 	if err != nil {
 		return err
 	}
@@ -427,11 +645,33 @@ func (mw *MainWindow) loadSymbols(symbols []*symbol.Symbol) {
 			Value:            s.Number,
 			Type:             s.Type,
 			Length:           s.Length,
+			Mask:             s.Mask,
 			Correctionfactor: s.Correctionfactor,
 			Unit:             s.Unit,
 		}
 	}
 	mw.symbolMap = newSymbolMap
+	mw.symbols = symbols
+}
+
+func (mw *MainWindow) setECUInfo(ident *kwp2000.ECUIdentification) {
+	mw.ecuInfo.SetText(ident.String())
+}
+
+// ecuError explains negative responses that need the user to do something before retrying
+func ecuError(err error) error {
+	var nrc *kwp2000.NegativeResponseError
+	switch {
+	case errors.Is(err, kwp2000.ErrSecurityAccessDenied), errors.Is(err, kwp2000.ErrInvalidKey):
+		return fmt.Errorf("The ECU denied security access, none of the known keys are accepted by this software: %w", err) //lint:ignore ST1005 ignore error
+	case errors.Is(err, kwp2000.ErrExceedNumberOfAttempts), errors.Is(err, kwp2000.ErrRequiredTimeDelayNotExpired):
+		return fmt.Errorf("The ECU is locked out after too many security attempts, turn the ignition off and on and try again: %w", err) //lint:ignore ST1005 ignore error
+	case errors.Is(err, kwp2000.ErrConditionsNotCorrect):
+		return fmt.Errorf("The ECU refused the request in its current state, check that the engine is off: %w", err) //lint:ignore ST1005 ignore error
+	case errors.As(err, &nrc) && nrc.Temporary():
+		return fmt.Errorf("The ECU is busy, try again: %w", err) //lint:ignore ST1005 ignore error
+	}
+	return err
 }
 
 func (mw *MainWindow) Log(s string) {
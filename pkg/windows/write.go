@@ -0,0 +1,98 @@
+package windows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/roffe/t7logger/pkg/ecu"
+	"github.com/roffe/t7logger/pkg/kwp2000"
+)
+
+func (mw *MainWindow) newWriteValueBtn() {
+	mw.writeValueBtn = widget.NewButtonWithIcon("Edit live value", theme.DocumentCreateIcon(), func() {
+		vars := mw.vars.Get()
+		if len(vars) == 0 {
+			dialog.ShowError(errors.New("Add the symbol to write to the symbol list first"), mw) //lint:ignore ST1005 ignore error
+			return
+		}
+		var names []string
+		for _, v := range vars {
+			names = append(names, v.Name)
+		}
+		symbolSelect := widget.NewSelect(names, nil)
+		valueEntry := widget.NewEntry()
+		dialog.ShowForm("Edit live value", "Write", "Cancel", []*widget.FormItem{
+			widget.NewFormItem("Symbol", symbolSelect),
+			widget.NewFormItem("Value", valueEntry),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			if symbolSelect.SelectedIndex() < 0 {
+				return
+			}
+			v, err := mw.resolveAddress(vars[symbolSelect.SelectedIndex()])
+			if err != nil {
+				dialog.ShowError(err, mw)
+				return
+			}
+			value, err := strconv.ParseFloat(strings.ReplaceAll(valueEntry.Text, ",", "."), 64)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("invalid value: %w", err), mw)
+				return
+			}
+			data, err := v.Encode(value)
+			if err != nil {
+				dialog.ShowError(err, mw)
+				return
+			}
+			msg := fmt.Sprintf("Write %s = %v%s (raw %X) to ECU RAM?\n\nThe value stays in effect until the ECU overwrites it or is restarted.", v.Name, value, v.Unit, data)
+			dialog.ShowConfirm("Confirm write", msg, func(ok bool) {
+				if !ok {
+					return
+				}
+				mw.writeValueBtn.Disable()
+				defer mw.writeValueBtn.Enable()
+				if err := mw.writeValue(v, data); err != nil {
+					mw.Log(fmt.Sprintf("Write %s = %v (raw %X) failed: %v", v.Name, value, data, err))
+					dialog.ShowError(ecuError(err), mw)
+					return
+				}
+				mw.Log(fmt.Sprintf("Wrote %s = %v (raw %X) by %s %d", v.Name, value, data, v.Method, v.Value))
+			}, mw)
+		}, mw)
+	})
+}
+
+// resolveAddress looks up the RAM address of variables defined by symbol number, writes are by address only
+func (mw *MainWindow) resolveAddress(v *kwp2000.VarDefinition) (*kwp2000.VarDefinition, error) {
+	if v.Method == kwp2000.VAR_METHOD_SYMBOL && mw.symbols == nil {
+		return nil, fmt.Errorf("Load symbols from binary or ECU first to look up the address of %s", v.Name) //lint:ignore ST1005 ignore error
+	}
+	return ecu.ResolveAddress(v, mw.symbols)
+}
+
+// writeValue writes through the logging session if logging is running, otherwise a new session is opened
+func (mw *MainWindow) writeValue(v *kwp2000.VarDefinition, data []byte) error {
+	if mw.loggingRunning && mw.dlc != nil {
+		return mw.dlc.Write(v, data)
+	}
+	opts, err := mw.canSettings.KWPOptions()
+	if err != nil {
+		return err
+	}
+	device, err := mw.canSettings.GetAdapter("T7", mw.Log)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	return ecu.WriteVar(ctx, device, v, data, opts...)
+}